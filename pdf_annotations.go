@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Something drawn on a chart to point out a value. The type is one of:
+//
+//  "line"     a horizontal reference line at value, or at the series' average, min or max when compute is set
+//  "band"     a shaded band between from and to, drawn behind the bars
+//  "callout"  a label above the bar for category, with a short line down to the bar
+//
+//The label is written with the annotation's font, and {value} in it is replaced with the line's value, or the bar's value for a
+//callout, written with valueFormat. Lines use the line width, colour and dash pattern of the format and bands use its fill colour,
+//which can be see-through. Defaults for annotations are in the "annotations" element defaults
+type ChartAnnotation struct {
+	Type        string      `json: type`
+	Value       float64     `json: value`
+	Compute     string      `json: compute`
+	From        float64     `json: from`
+	To          float64     `json: to`
+	Category    string      `json: category`
+	Label       string      `json: label`
+	ValueFormat ValueFormat `json: valueFormat`
+	Format      ShapeStyle  `json: format`
+	Font        Font        `json: font`
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a chart's annotations against the categories in its data
+func ValidateChartAnnotations(annotations []ChartAnnotation, categories []string) (err error) {
+
+	for i, annotation := range annotations {
+		switch annotation.Type {
+		case "line":
+			if annotation.Compute != "" && annotation.Compute != "average" && annotation.Compute != "min" && annotation.Compute != "max" {
+				return fmt.Errorf("annotation %d has unsupported compute %q, expected average, min or max", i, annotation.Compute)
+			}
+		case "band":
+			if annotation.From >= annotation.To {
+				return fmt.Errorf("annotation %d band goes from %v to %v, from should be below to", i, annotation.From, annotation.To)
+			}
+		case "callout":
+			found := false
+			for _, category := range categories {
+				found = found || category == annotation.Category
+			}
+			if !found {
+				return fmt.Errorf("annotation %d is a callout for category %q, which isn't in the chart's data", i, annotation.Category)
+			}
+		default:
+			return fmt.Errorf("annotation %d has unsupported type %q, expected line, band or callout", i, annotation.Type)
+		}
+		if err = ValidateValueFormat(annotation.ValueFormat); err != nil {
+			return fmt.Errorf("annotation %d value format: %v", i, err)
+		}
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the bands, which go behind the bars. Each band's label sits in its top left corner
+func DrawChartBands(pdf *gofpdf.Fpdf, annotations []ChartAnnotation, plotArea ChartPlotArea) {
+
+	for _, annotation := range annotations {
+		if annotation.Type != "band" {
+			continue
+		}
+		bandTop := plotArea.ClampedYPosition(annotation.To)
+		bandBottom := plotArea.ClampedYPosition(annotation.From)
+
+		pdf.SetFillColor(annotation.Format.FillColour.R, annotation.Format.FillColour.G, annotation.Format.FillColour.B)
+		DrawWithAlpha(pdf, annotation.Format.FillColour, func() {
+			pdf.Rect(plotArea.Left, bandTop, plotArea.Right-plotArea.Left, bandBottom-bandTop, "F")
+		})
+
+		label := annotationLabel(annotation, annotation.To)
+		drawAnnotationLabel(pdf, annotation.Font, label, plotArea.Left, bandTop, plotArea.Right-plotArea.Left, "LT")
+	}
+}
+
+//Drawing the reference lines and callouts, which go on top of the bars. A line's label sits above its right hand end
+func DrawChartAnnotations(pdf *gofpdf.Fpdf, annotations []ChartAnnotation, plotArea ChartPlotArea, categories []string, values []float64) {
+
+	for _, annotation := range annotations {
+		switch annotation.Type {
+
+		case "line":
+			lineValue := annotation.Value
+			if annotation.Compute != "" {
+				lineValue = computeSeriesValue(annotation.Compute, values)
+			}
+			lineYPosition := plotArea.ClampedYPosition(lineValue)
+
+			pdf.SetLineWidth(annotation.Format.LineWidth)
+			pdf.SetDrawColor(annotation.Format.LineColour.R, annotation.Format.LineColour.G, annotation.Format.LineColour.B)
+			pdf.SetDashPattern(annotation.Format.DashPattern, 0)
+			DrawWithAlpha(pdf, annotation.Format.LineColour, func() {
+				pdf.Line(plotArea.Left, lineYPosition, plotArea.Right, lineYPosition)
+			})
+			pdf.SetDashPattern([]float64{}, 0)
+
+			labelHeight := annotation.Font.Size + annotation.Font.LineSpacing
+			drawAnnotationLabel(pdf, annotation.Font, annotationLabel(annotation, lineValue), plotArea.Left, lineYPosition-labelHeight, plotArea.Right-plotArea.Left, "RB")
+
+		case "callout":
+			for i, category := range categories {
+				if category != annotation.Category {
+					continue
+				}
+				//The leader line runs up from the top of the bar, and the label sits on top of it without leaving the plot area
+				labelHeight := annotation.Font.Size + annotation.Font.LineSpacing
+				centreX := plotArea.CategoryCentre(i)
+				barTop := plotArea.ClampedYPosition(values[i])
+				leaderTop := math.Max(plotArea.Top+labelHeight, barTop-2*labelHeight)
+
+				pdf.SetLineWidth(annotation.Format.LineWidth)
+				pdf.SetDrawColor(annotation.Format.LineColour.R, annotation.Format.LineColour.G, annotation.Format.LineColour.B)
+				DrawWithAlpha(pdf, annotation.Format.LineColour, func() {
+					pdf.Line(centreX, barTop, centreX, leaderTop)
+				})
+
+				label := annotationLabel(annotation, values[i])
+				pdf.SetFont(annotation.Font.Family, annotation.Font.Style, annotation.Font.Size)
+				labelWidth := pdf.GetStringWidth(label) + 2*pdf.GetCellMargin()
+				drawAnnotationLabel(pdf, annotation.Font, label, centreX-0.5*labelWidth, leaderTop-labelHeight, labelWidth, "CB")
+			}
+		}
+	}
+}
+
+//The annotation's label with {value} filled in
+func annotationLabel(annotation ChartAnnotation, value float64) string {
+	return strings.Replace(annotation.Label, "{value}", FormatValue(value, annotation.ValueFormat), -1)
+}
+
+//Writing an annotation's label in a cell, with the cell's fill and borders from the font
+func drawAnnotationLabel(pdf *gofpdf.Fpdf, font Font, label string, x, y, width float64, alignment string) {
+
+	if label == "" {
+		return
+	}
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(width, font.Size+font.LineSpacing, TextForFont(pdf, font.Family, label), font.CellBorders.Style, 0, alignment, font.CellFill.Filled, 0, "")
+}
+
+//Working out a value from the series for a reference line
+func computeSeriesValue(compute string, values []float64) float64 {
+
+	if len(values) == 0 {
+		return 0.0
+	}
+
+	computedValue := values[0]
+	sum := 0.0
+	for _, value := range values {
+		sum = sum + value
+		switch compute {
+		case "min":
+			computedValue = math.Min(computedValue, value)
+		case "max":
+			computedValue = math.Max(computedValue, value)
+		}
+	}
+	if compute == "average" {
+		computedValue = sum / float64(len(values))
+	}
+
+	return computedValue
+}
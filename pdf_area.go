@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Area charts draw each series as a line through the middle of its categories, filled down to the x axis. Stacked area charts start
+//each series on top of the one before it, so the top of the last series is the total. The fills are drawn in the series' colours,
+//which can be see-through so that the areas behind show, and the line along the top of each one is drawn in the same colour, opaque,
+//in the series format's line width
+//
+//Annotations are drawn against the top of the areas at each category, the total on a stacked chart, so a callout points at the
+//highest area and a computed line is the average, min or max of those tops. Data labels are written against each series' own area
+//
+//  "itemType": "stackedArea",
+//  "dataSource": "Number of cheeses sold per month", "dataSeriesCategory": "Month",
+//  "series": [{"key": "Cheddars"}, {"key": "Bries"}]
+
+//////////////////////////////////////////////////////////////////////
+//Processing area and stacked area charts
+func ProcessAreaChartPDFItem(pdf *gofpdf.Fpdf, areaItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+
+	if err = ValidateCategoryChart(areaItem); err != nil {
+		return err
+	}
+	series, err := ChartSeriesForItem(areaItem)
+	if err != nil {
+		return err
+	}
+	if err = ValidateDataLabels(areaItem.ChartSettings.DataLabels); err != nil {
+		return err
+	}
+	chartSettings := areaItem.ChartSettings
+	stacked := areaItem.ItemType == "stackedArea"
+
+	for _, dataset := range data {
+		if areaItem.DataSource == dataset.DataSource {
+
+			var categoryValues []interface{}
+			var categories []string
+			for _, dataPoint := range dataset.DataPoints {
+				categoryValues = append(categoryValues, dataPoint.(map[string]interface{})[areaItem.DataSeriesCategory])
+				categories = append(categories, FormatCellValue(dataPoint.(map[string]interface{})[areaItem.DataSeriesCategory]))
+			}
+			if err = ValidateChartAnnotations(chartSettings.Annotations, categories); err != nil {
+				return err
+			}
+			seriesValues, err := chartSeriesValues(areaItem, series, dataset.DataPoints)
+			if err != nil {
+				return err
+			}
+
+			//Working out the bottom and top of each series' area. Stacked series sit on the top of the series before them
+			seriesBottoms := make([][]float64, len(series))
+			seriesTops := make([][]float64, len(series))
+			maxValueFromData := 0.0
+			for seriesIndex, values := range seriesValues {
+				seriesBottoms[seriesIndex] = make([]float64, len(values))
+				seriesTops[seriesIndex] = make([]float64, len(values))
+				for categoryIndex, value := range values {
+					if stacked && seriesIndex > 0 {
+						seriesBottoms[seriesIndex][categoryIndex] = seriesTops[seriesIndex-1][categoryIndex]
+					}
+					seriesTops[seriesIndex][categoryIndex] = seriesBottoms[seriesIndex][categoryIndex] + value
+					if seriesTops[seriesIndex][categoryIndex] > maxValueFromData {
+						maxValueFromData = seriesTops[seriesIndex][categoryIndex]
+					}
+				}
+			}
+
+			//The top of the highest area at each category, for the annotations
+			areaTops := make([]float64, len(categoryValues))
+			for categoryIndex := range areaTops {
+				for seriesIndex := range seriesTops {
+					if seriesIndex == 0 || seriesTops[seriesIndex][categoryIndex] > areaTops[categoryIndex] {
+						areaTops[categoryIndex] = seriesTops[seriesIndex][categoryIndex]
+					}
+				}
+			}
+
+			minValueFromData := maxValueFromData
+			for _, values := range seriesValues {
+				seriesMinValue, _ := valueRange(values)
+				minValueFromData = math.Min(minValueFromData, seriesMinValue)
+			}
+			valueScale, err := CategoryValueScale(chartSettings.YAxisType, chartSettings.NumberOfYAxisTicks, minValueFromData, maxValueFromData)
+			if err != nil {
+				return err
+			}
+			plotArea, err := CategoryPlotArea(areaItem, pdfSettings, categoryValues, valueScale)
+			if err != nil {
+				return err
+			}
+
+			DrawChartBackground(pdf, chartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, areaItem.Width, areaItem.Height)
+			DrawCategoryChartValueAxis(pdf, chartSettings, plotArea)
+			DrawChartBands(pdf, chartSettings.Annotations, plotArea)
+
+			//Drawing the areas in order, so that later series are drawn over the earlier ones
+			for seriesIndex, chartSeries := range series {
+				var outline []gofpdf.PointType
+				for categoryIndex := range seriesTops[seriesIndex] {
+					outline = append(outline, gofpdf.PointType{X: plotArea.CategoryCentre(categoryIndex), Y: plotArea.YPosition(seriesTops[seriesIndex][categoryIndex])})
+				}
+				area := append([]gofpdf.PointType{}, outline...)
+				for categoryIndex := len(seriesBottoms[seriesIndex]) - 1; categoryIndex >= 0; categoryIndex-- {
+					area = append(area, gofpdf.PointType{X: plotArea.CategoryCentre(categoryIndex), Y: plotArea.YPosition(seriesBottoms[seriesIndex][categoryIndex])})
+				}
+
+				colour := *chartSeries.Colour
+				pdf.SetFillColor(colour.R, colour.G, colour.B)
+				DrawWithAlpha(pdf, colour, func() {
+					pdf.Polygon(area, "F")
+				})
+
+				if chartSettings.SeriesFormat.LineWidth > 0.0 {
+					pdf.SetLineWidth(chartSettings.SeriesFormat.LineWidth)
+					pdf.SetDrawColor(colour.R, colour.G, colour.B)
+					for i := 1; i < len(outline); i++ {
+						pdf.Line(outline[i-1].X, outline[i-1].Y, outline[i].X, outline[i].Y)
+					}
+				}
+			}
+
+			//Writing each series' values against its own area, from its bottom up to its top
+			if chartSettings.DataLabels.Show {
+				for seriesIndex, values := range seriesValues {
+					for categoryIndex, value := range values {
+						areaBottom := plotArea.YPosition(seriesBottoms[seriesIndex][categoryIndex])
+						areaHeight := areaBottom - plotArea.YPosition(seriesTops[seriesIndex][categoryIndex])
+						DrawBarDataLabel(pdf, chartSettings.DataLabels, value, plotArea.CategoryCentre(categoryIndex)-0.5*plotArea.CategoryWidth, plotArea.CategoryWidth, areaBottom, areaHeight, plotArea.Top)
+					}
+				}
+			}
+
+			DrawCategoryChartCategoryAxis(pdf, chartSettings, plotArea, categoryValues)
+			DrawChartAnnotations(pdf, chartSettings.Annotations, plotArea, categories, areaTops)
+			DrawChartLegend(pdf, chartSettings.Legend, series, plotArea)
+
+			//Adding the chart and axis titles
+			DrawChartTitle(pdf, chartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, areaItem.Width)
+			DrawXAxisTitle(pdf, chartSettings.XAxisTitle, plotArea.ChartBoxX, areaItem.Width, plotArea.ChartBoxY+areaItem.Height)
+			DrawYAxisTitle(pdf, chartSettings.YAxisTitle, plotArea.ChartBoxX, plotArea.Top, plotArea.Bottom)
+		}
+	}
+
+	return err
+}
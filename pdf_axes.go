@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Lines across the chart's plot area, horizontal ones at each y axis tick and vertical ones between the categories. They're drawn
+//behind the bars in the line width, colour and dash pattern of their format
+type Gridlines struct {
+	Show   bool       `json: show`
+	Format ShapeStyle `json: format`
+}
+
+//A title for an axis. The x axis title sits at the bottom of the chart box and the y axis title runs up the left edge of the chart
+//box, turned 90°, so distanceFromBottomOfChartArea and distanceFromSidesOfChartArea need to leave room for them
+type AxisTitle struct {
+	Text string `json: text`
+	Font Font   `json: font`
+}
+
+//What happens to category labels that are wider than their category, set with xAxisLabelFit:
+//
+//  "rotate"  every label is turned 45° so that long labels hang down from their tick
+//  "wrap"    the labels are wrapped onto more lines within their category
+//
+//Labels are only rotated or wrapped when at least one of them doesn't fit
+var categoryLabelFits = map[string]bool{"rotate": true, "wrap": true}
+
+//The angle category labels are turned to when they're rotated
+const rotatedCategoryLabelAngle = 45.0
+
+//////////////////////////////////////////////////////////////////////
+//Checking a chart's axis settings
+func ValidateChartAxes(chartSettings ChartSettings) (err error) {
+
+	if !valueAxisTypes[chartSettings.YAxisType] {
+		return fmt.Errorf("unsupported y axis type %q, expected linear or log", chartSettings.YAxisType)
+	}
+	if !categoryLabelFits[chartSettings.XAxisLabelFit] {
+		return fmt.Errorf("unsupported x axis label fit %q, expected rotate or wrap", chartSettings.XAxisLabelFit)
+	}
+	for _, gridlines := range []Gridlines{chartSettings.HorizontalGridlines, chartSettings.VerticalGridlines} {
+		for _, dash := range gridlines.Format.DashPattern {
+			if dash < 0.0 {
+				return fmt.Errorf("gridline dash pattern %v can't have negative lengths", gridlines.Format.DashPattern)
+			}
+		}
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing a gridline from one point to another when the gridlines are shown
+func DrawGridline(pdf *gofpdf.Fpdf, gridlines Gridlines, x1, y1, x2, y2 float64) {
+
+	if !gridlines.Show {
+		return
+	}
+
+	pdf.SetLineWidth(gridlines.Format.LineWidth)
+	pdf.SetDrawColor(gridlines.Format.LineColour.R, gridlines.Format.LineColour.G, gridlines.Format.LineColour.B)
+	pdf.SetDashPattern(gridlines.Format.DashPattern, 0)
+	DrawWithAlpha(pdf, gridlines.Format.LineColour, func() {
+		pdf.Line(x1, y1, x2, y2)
+	})
+	pdf.SetDashPattern([]float64{}, 0)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing the x axis title centred across the chart, with the bottom of the title on the bottom of the chart box
+func DrawXAxisTitle(pdf *gofpdf.Fpdf, title AxisTitle, chartBoxX, chartBoxWidth, chartBoxBottom float64) {
+
+	if title.Text == "" {
+		return
+	}
+
+	titleHeight := title.Font.Size + title.Font.LineSpacing
+	pdf.SetFont(title.Font.Family, title.Font.Style, title.Font.Size)
+	pdf.SetTextColor(title.Font.Colour.R, title.Font.Colour.G, title.Font.Colour.B)
+	pdf.SetXY(chartBoxX, chartBoxBottom-titleHeight)
+	pdf.CellFormat(chartBoxWidth, titleHeight, TextForFont(pdf, title.Font.Family, title.Text), "", 0, "CM", false, 0, "")
+}
+
+//Writing the y axis title turned 90° so that it reads upwards, against the left of the chart box and centred on the y axis
+func DrawYAxisTitle(pdf *gofpdf.Fpdf, title AxisTitle, chartBoxX, yAxisTop, yAxisBottom float64) {
+
+	if title.Text == "" {
+		return
+	}
+
+	titleHeight := title.Font.Size + title.Font.LineSpacing
+	titleLength := yAxisBottom - yAxisTop
+	centreX := chartBoxX + 0.5*titleHeight
+	centreY := yAxisTop + 0.5*titleLength
+
+	pdf.SetFont(title.Font.Family, title.Font.Style, title.Font.Size)
+	pdf.SetTextColor(title.Font.Colour.R, title.Font.Colour.G, title.Font.Colour.B)
+	pdf.TransformBegin()
+	pdf.TransformRotate(90, centreX, centreY)
+	pdf.SetXY(centreX-0.5*titleLength, centreY-0.5*titleHeight)
+	pdf.CellFormat(titleLength, titleHeight, TextForFont(pdf, title.Font.Family, title.Text), "", 0, "CM", false, 0, "")
+	pdf.TransformEnd()
+}
+
+//Writing the secondary y axis title turned the other way so that it reads downwards, against the right of the chart box
+func DrawSecondaryYAxisTitle(pdf *gofpdf.Fpdf, title AxisTitle, chartBoxRight, yAxisTop, yAxisBottom float64) {
+
+	if title.Text == "" {
+		return
+	}
+
+	titleHeight := title.Font.Size + title.Font.LineSpacing
+	titleLength := yAxisBottom - yAxisTop
+	centreX := chartBoxRight - 0.5*titleHeight
+	centreY := yAxisTop + 0.5*titleLength
+
+	pdf.SetFont(title.Font.Family, title.Font.Style, title.Font.Size)
+	pdf.SetTextColor(title.Font.Colour.R, title.Font.Colour.G, title.Font.Colour.B)
+	pdf.TransformBegin()
+	pdf.TransformRotate(270, centreX, centreY)
+	pdf.SetXY(centreX-0.5*titleLength, centreY-0.5*titleHeight)
+	pdf.CellFormat(titleLength, titleHeight, TextForFont(pdf, title.Font.Family, title.Text), "", 0, "CM", false, 0, "")
+	pdf.TransformEnd()
+}
+
+//The room the y axis title takes up at the left of the chart box
+func YAxisTitleWidth(title AxisTitle) float64 {
+	if title.Text == "" {
+		return 0.0
+	}
+	return title.Font.Size + title.Font.LineSpacing
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking whether every category label fits its category, with the font already set. Returns how the labels should be drawn,
+//"fit" when they all fit or the chart's xAxisLabelFit when they don't
+func CategoryLabelLayout(pdf *gofpdf.Fpdf, labels []string, categoryWidth float64, labelFit string) string {
+	for _, label := range labels {
+		if pdf.GetStringWidth(label)+2*pdf.GetCellMargin() > categoryWidth {
+			return labelFit
+		}
+	}
+	return "fit"
+}
+
+//Writing a category label under its category, starting at the top of the label area
+func DrawCategoryLabel(pdf *gofpdf.Fpdf, label string, layout string, categoryXPosition, categoryWidth, labelYPosition, lineHeight float64) {
+
+	switch layout {
+
+	case "rotate":
+		//The end of the label is pinned under the middle of the category and the rest of it hangs down to the left
+		anchorX := categoryXPosition + 0.5*categoryWidth
+		labelWidth := pdf.GetStringWidth(label) + 2*pdf.GetCellMargin()
+		pdf.TransformBegin()
+		pdf.TransformRotate(rotatedCategoryLabelAngle, anchorX, labelYPosition)
+		pdf.SetXY(anchorX-labelWidth, labelYPosition)
+		pdf.CellFormat(labelWidth, lineHeight, label, "", 0, "RT", false, 0, "")
+		pdf.TransformEnd()
+
+	case "wrap":
+		pdf.SetXY(categoryXPosition, labelYPosition)
+		pdf.MultiCell(categoryWidth, lineHeight, label, "", "C", false)
+
+	default:
+		pdf.SetXY(categoryXPosition, labelYPosition)
+		pdf.CellFormat(categoryWidth, lineHeight, label, "", 0, "CM", false, 0, "")
+	}
+}
+
+//The plot area of a chart with a category x axis and a value y axis, for drawing things against the chart's scale. Left and right
+//are the ends of the x axis, top is the top of the y axis and bottom is the x axis. The categories each get an equal width along
+//the x axis, or on a time axis they're placed by their dates and the width is the closest any two of them are
+type ChartPlotArea struct {
+	ChartBoxX       float64
+	ChartBoxY       float64
+	Left            float64
+	Right           float64
+	Top             float64
+	Bottom          float64
+	ValueScale      AxisScale
+	CategoryCentres []float64
+	CategoryWidth   float64
+	TimeRange       *TimeAxisRange
+}
+
+//The y position of a value on the chart's scale
+func (plotArea ChartPlotArea) YPosition(value float64) float64 {
+	return plotArea.ValueScale.Position(value, plotArea.Bottom, plotArea.Top)
+}
+
+//The y position of a value, kept inside the plot area
+func (plotArea ChartPlotArea) ClampedYPosition(value float64) float64 {
+	return math.Max(plotArea.Top, math.Min(plotArea.Bottom, plotArea.YPosition(value)))
+}
+
+//The x position of the middle of a category
+func (plotArea ChartPlotArea) CategoryCentre(categoryIndex int) float64 {
+	return plotArea.CategoryCentres[categoryIndex]
+}
+
+//The x position of a date on a time axis
+func (plotArea ChartPlotArea) TimePosition(date time.Time) float64 {
+	return plotArea.Left + float64(date.Sub(plotArea.TimeRange.Start))/float64(plotArea.TimeRange.End.Sub(plotArea.TimeRange.Start))*(plotArea.Right-plotArea.Left)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Laying out the plot area of a chart with a category x axis, inside the chart box and its distances from the edges
+func CategoryPlotArea(chartItem PdfContentItem, pdfSettings PdfFields, categoryValues []interface{}, valueScale AxisScale) (plotArea ChartPlotArea, err error) {
+
+	plotArea.ChartBoxX = chartItem.XPosition + pdfSettings.PdfSettings.PageLeftAndRightMargins
+	plotArea.ChartBoxY = chartItem.YPosition + pdfSettings.PdfSettings.PageTopMargin
+	plotArea.Left = plotArea.ChartBoxX + chartItem.ChartSettings.DistanceFromSidesOfChartArea
+	plotArea.Right = plotArea.ChartBoxX + chartItem.Width - chartItem.ChartSettings.DistanceFromSidesOfChartArea
+	plotArea.Top = plotArea.ChartBoxY + chartItem.ChartSettings.DistanceFromTopOfChartArea
+	plotArea.Bottom = plotArea.ChartBoxY + chartItem.Height - chartItem.ChartSettings.DistanceFromBottomOfChartArea
+	plotArea.ValueScale = valueScale
+
+	//The categories share the axis between them, and a time axis runs between the first and last dates, so there has to be one
+	if len(categoryValues) == 0 {
+		return plotArea, fmt.Errorf("%s chart data source %q has no points", chartItem.ItemType, chartItem.DataSource)
+	}
+
+	if chartItem.ChartSettings.XAxisType != "time" {
+		plotArea.CategoryWidth = (plotArea.Right - plotArea.Left) / float64(len(categoryValues))
+		for i := range categoryValues {
+			plotArea.CategoryCentres = append(plotArea.CategoryCentres, plotArea.Left+(float64(i)+0.5)*plotArea.CategoryWidth)
+		}
+		return plotArea, nil
+	}
+
+	//On a time axis every date gets the room of the closest two dates, and the axis runs half of that past the first and last dates
+	dates, err := TimeAxisDates(categoryValues, chartItem.ChartSettings.XAxisLabelFormat.DateInputLayout)
+	if err != nil {
+		return plotArea, err
+	}
+	closestDates := 24 * time.Hour
+	for i := 1; i < len(dates); i++ {
+		if i == 1 || dates[i].Sub(dates[i-1]) < closestDates {
+			closestDates = dates[i].Sub(dates[i-1])
+		}
+	}
+	plotArea.TimeRange = &TimeAxisRange{Start: dates[0].Add(-closestDates / 2), End: dates[len(dates)-1].Add(closestDates / 2)}
+	plotArea.CategoryWidth = float64(closestDates) / float64(plotArea.TimeRange.End.Sub(plotArea.TimeRange.Start)) * (plotArea.Right - plotArea.Left)
+	for _, date := range dates {
+		plotArea.CategoryCentres = append(plotArea.CategoryCentres, plotArea.TimePosition(date))
+	}
+
+	return plotArea, nil
+}
+
+//Checking the axis settings shared by the charts with a category x axis
+func ValidateCategoryChart(chartItem PdfContentItem) (err error) {
+
+	if chartItem.ChartSettings.NumberOfYAxisTicks < 2 {
+		return fmt.Errorf("%s chart for %q needs at least 2 y axis ticks, got %v", chartItem.ItemType, chartItem.DataSource, chartItem.ChartSettings.NumberOfYAxisTicks)
+	}
+	if !categoryXAxisTypes[chartItem.ChartSettings.XAxisType] {
+		return fmt.Errorf("unsupported x axis type %q for a %s chart, expected category or time", chartItem.ChartSettings.XAxisType, chartItem.ItemType)
+	}
+	if err = ValidateValueFormat(chartItem.ChartSettings.XAxisLabelFormat); err != nil {
+		return fmt.Errorf("x axis label format: %v", err)
+	}
+	if err = ValidateValueFormat(chartItem.ChartSettings.YAxisLabelFormat); err != nil {
+		return fmt.Errorf("y axis label format: %v", err)
+	}
+
+	return ValidateChartAxes(chartItem.ChartSettings)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the y axis of a category chart with its ticks, labels and horizontal gridlines, and the vertical gridlines between the
+//categories, or at the ticks of a time axis
+func DrawCategoryChartValueAxis(pdf *gofpdf.Fpdf, chartSettings ChartSettings, plotArea ChartPlotArea) {
+
+	font := chartSettings.ChartTextFont
+
+	//Drawing the y axis
+	pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+	pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+	pdf.Line(plotArea.Left, plotArea.Top, plotArea.Left, plotArea.Bottom)
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	tickLength := chartSettings.TickMarkLength
+	yAxisTitleWidth := YAxisTitleWidth(chartSettings.YAxisTitle)
+	for _, tickValue := range plotArea.ValueScale.Ticks() {
+		tickYPosition := plotArea.YPosition(tickValue)
+
+		//Drawing the gridline across the chart, the x axis covers the one at the bottom
+		DrawGridline(pdf, chartSettings.HorizontalGridlines, plotArea.Left, tickYPosition, plotArea.Right, tickYPosition)
+
+		//Drawing the tick line
+		pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+		pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+		pdf.Line(plotArea.Left, tickYPosition, plotArea.Left-tickLength, tickYPosition)
+		//Calculating the position of the tick labels
+		////We work out the gap between the yaxis and chart box, set label to yaxis, but justify the position of the label text right
+		////The y axis title, if there is one, takes up the left of the gap
+		pdf.SetXY(plotArea.ChartBoxX+yAxisTitleWidth, tickYPosition-(0.5*font.Size))
+		pdf.CellFormat(chartSettings.DistanceFromSidesOfChartArea-tickLength-yAxisTitleWidth, font.Size, TextForFont(pdf, font.Family, plotArea.ValueScale.TickLabel(tickValue, chartSettings.YAxisLabelFormat)), "", 0, "RM", false, 0, "")
+	}
+
+	//Drawing the vertical gridlines
+	if plotArea.TimeRange != nil {
+		ticks, _ := TimeAxisTicks(*plotArea.TimeRange)
+		for _, tick := range ticks {
+			gridlineXPosition := plotArea.TimePosition(tick)
+			DrawGridline(pdf, chartSettings.VerticalGridlines, gridlineXPosition, plotArea.Bottom, gridlineXPosition, plotArea.Top)
+		}
+		return
+	}
+	for i := range plotArea.CategoryCentres {
+		gridlineXPosition := plotArea.CategoryCentre(i) + 0.5*plotArea.CategoryWidth
+		DrawGridline(pdf, chartSettings.VerticalGridlines, gridlineXPosition, plotArea.Bottom, gridlineXPosition, plotArea.Top)
+	}
+}
+
+//Drawing the x axis of a category chart with a tick between each category and the categories' labels under them. A time axis has
+//its ticks at the start of each day, week, month or quarter instead, labelled with their dates
+func DrawCategoryChartCategoryAxis(pdf *gofpdf.Fpdf, chartSettings ChartSettings, plotArea ChartPlotArea, categoryValues []interface{}) {
+
+	font := chartSettings.ChartTextFont
+	tickLength := chartSettings.TickMarkLength
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+	pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+
+	if plotArea.TimeRange != nil {
+		ticks, labelLayout := TimeAxisTicks(*plotArea.TimeRange)
+		if chartSettings.XAxisLabelFormat.DateLayout != "" {
+			labelLayout = chartSettings.XAxisLabelFormat.DateLayout
+		}
+		//Each label is centred on its tick, with the room of the closest ticks
+		var tickLabels []string
+		labelWidth := plotArea.Right - plotArea.Left
+		for i, tick := range ticks {
+			tickLabels = append(tickLabels, TextForFont(pdf, font.Family, formatTimeAxisTick(tick, labelLayout)))
+			if i > 0 {
+				labelWidth = math.Min(labelWidth, plotArea.TimePosition(tick)-plotArea.TimePosition(ticks[i-1]))
+			}
+		}
+		tickLabelLayout := CategoryLabelLayout(pdf, tickLabels, labelWidth, chartSettings.XAxisLabelFit)
+		for i, tick := range ticks {
+			tickXPosition := plotArea.TimePosition(tick)
+			pdf.Line(tickXPosition, plotArea.Bottom, tickXPosition, plotArea.Bottom+tickLength)
+			DrawCategoryLabel(pdf, tickLabels[i], tickLabelLayout, tickXPosition-0.5*labelWidth, labelWidth, plotArea.Bottom+(0.5*tickLength), font.Size)
+		}
+		pdf.Line(plotArea.Left, plotArea.Bottom, plotArea.Right, plotArea.Bottom)
+		return
+	}
+
+	//Category labels that don't fit their category are rotated or wrapped, all of them together so that they match
+	var categoryLabels []string
+	for _, categoryValue := range categoryValues {
+		categoryLabels = append(categoryLabels, TextForFont(pdf, font.Family, FormatValue(categoryValue, chartSettings.XAxisLabelFormat)))
+	}
+	categoryLabelLayout := CategoryLabelLayout(pdf, categoryLabels, plotArea.CategoryWidth, chartSettings.XAxisLabelFit)
+
+	tickXPosition := plotArea.Left
+	for _, categoryLabel := range categoryLabels {
+		//Drawing the tick line
+		pdf.Line(tickXPosition, plotArea.Bottom, tickXPosition, plotArea.Bottom+tickLength)
+		//Calculating the position of the tick labels
+		DrawCategoryLabel(pdf, categoryLabel, categoryLabelLayout, tickXPosition, plotArea.CategoryWidth, plotArea.Bottom+(0.5*tickLength), font.Size)
+		tickXPosition = tickXPosition + plotArea.CategoryWidth
+	}
+	//Drawing final tickmark on x axis
+	pdf.Line(tickXPosition, plotArea.Bottom, tickXPosition, plotArea.Bottom+tickLength)
+
+	//Drawing the x axis line
+	pdf.Line(plotArea.Left, plotArea.Bottom, plotArea.Right, plotArea.Bottom)
+}
+
+//A numeric axis running from min to max with a tick every step. On a log scale the ticks are at each power of ten, see LogAxisScale
+type AxisScale struct {
+	Min  float64
+	Max  float64
+	Step float64
+	Log  bool
+}
+
+//////////////////////////////////////////////////////////////////////
+//Working out a scale for values between minValue and maxValue with roughly the number of ticks asked for. The ends and the step are
+//rounded to 1, 2 or 5 times a power of ten, so the ticks can land either side of the number asked for
+func NiceAxisScale(minValue, maxValue, ticks float64) (scale AxisScale) {
+
+	if minValue == maxValue {
+		minValue, maxValue = minValue-1.0, maxValue+1.0
+	}
+	if ticks < 2 {
+		ticks = 2
+	}
+
+	span := niceNumber(maxValue-minValue, false)
+	scale.Step = niceNumber(span/(ticks-1), true)
+	scale.Min = math.Floor(minValue/scale.Step) * scale.Step
+	scale.Max = math.Ceil(maxValue/scale.Step) * scale.Step
+
+	return scale
+}
+
+//The tick values from the bottom of the scale to the top
+func (scale AxisScale) Ticks() (ticks []float64) {
+	if scale.Log {
+		for tick := scale.Min; tick <= scale.Max*1.000001; tick = tick * 10.0 {
+			ticks = append(ticks, tick)
+		}
+		return ticks
+	}
+	for i := 0; scale.Min+float64(i)*scale.Step <= scale.Max+0.5*scale.Step; i++ {
+		ticks = append(ticks, scale.Min+float64(i)*scale.Step)
+	}
+	return ticks
+}
+
+//Where a value sits between the start of the axis, at the scale's min, and its end, at the scale's max. On a log scale values below
+//the min, like the 0 at the bottom of a bar or area, are put at the start
+func (scale AxisScale) Position(value, start, end float64) float64 {
+	if scale.Log {
+		value = math.Max(value, scale.Min)
+		return start + (math.Log10(value)-math.Log10(scale.Min))/(math.Log10(scale.Max)-math.Log10(scale.Min))*(end-start)
+	}
+	return start + (value-scale.Min)/(scale.Max-scale.Min)*(end-start)
+}
+
+//The label for a tick in the axis' label format. Ticks below 1 on a log scale get as many decimals as they need, so 0.01 isn't
+//written as 0
+func (scale AxisScale) TickLabel(tickValue float64, format ValueFormat) string {
+	if scale.Log && tickValue > 0.0 && tickValue < 1.0 {
+		neededDecimals := int(math.Ceil(-math.Log10(tickValue) - 1e-9))
+		if format.Percentage {
+			neededDecimals -= 2
+		}
+		if neededDecimals > maximumFormatDecimals {
+			neededDecimals = maximumFormatDecimals
+		}
+		if format.Decimals < neededDecimals {
+			format.Decimals = neededDecimals
+		}
+	}
+	return FormatValue(tickValue, format)
+}
+
+//Rounding a number to 1, 2 or 5 times a power of ten, to the nearest when round is set and upwards when it isn't
+func niceNumber(number float64, round bool) float64 {
+
+	exponent := math.Floor(math.Log10(number))
+	fraction := number / math.Pow(10, exponent)
+
+	niceFraction := 10.0
+	if round {
+		switch {
+		case fraction < 1.5:
+			niceFraction = 1.0
+		case fraction < 3.0:
+			niceFraction = 2.0
+		case fraction < 7.0:
+			niceFraction = 5.0
+		}
+	} else {
+		switch {
+		case fraction <= 1.0:
+			niceFraction = 1.0
+		case fraction <= 2.0:
+			niceFraction = 2.0
+		case fraction <= 5.0:
+			niceFraction = 5.0
+		}
+	}
+
+	return niceFraction * math.Pow(10, exponent)
+}
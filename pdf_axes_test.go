@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNiceAxisScale(t *testing.T) {
+
+	tests := []struct {
+		minValue, maxValue, ticks float64
+		want                      AxisScale
+	}{
+		{0, 95, 5, AxisScale{Min: 0, Max: 100, Step: 20}},
+		{-3.2, 7.9, 6, AxisScale{Min: -5, Max: 10, Step: 5}},
+		{120, 480, 5, AxisScale{Min: 100, Max: 500, Step: 100}},
+		{0.02, 0.87, 5, AxisScale{Min: 0, Max: 1, Step: 0.2}},
+		//A single value gets a scale either side of it, and fewer than 2 ticks is treated as 2
+		{5, 5, 5, AxisScale{Min: 4, Max: 6, Step: 0.5}},
+		{0, 10, 1, AxisScale{Min: 0, Max: 10, Step: 10}},
+	}
+
+	for _, test := range tests {
+		scale := NiceAxisScale(test.minValue, test.maxValue, test.ticks)
+		if math.Abs(scale.Min-test.want.Min) > 1e-9 || math.Abs(scale.Max-test.want.Max) > 1e-9 || math.Abs(scale.Step-test.want.Step) > 1e-9 || scale.Log {
+			t.Errorf("NiceAxisScale(%v, %v, %v) = %+v, want %+v", test.minValue, test.maxValue, test.ticks, scale, test.want)
+		}
+		if scale.Min > test.minValue || scale.Max < test.maxValue {
+			t.Errorf("NiceAxisScale(%v, %v, %v) = %+v doesn't cover the values", test.minValue, test.maxValue, test.ticks, scale)
+		}
+	}
+}
+
+func TestAxisScaleTicks(t *testing.T) {
+
+	tests := []struct {
+		scale AxisScale
+		want  []float64
+	}{
+		{AxisScale{Min: 0, Max: 100, Step: 25}, []float64{0, 25, 50, 75, 100}},
+		{AxisScale{Min: -0.4, Max: 0.4, Step: 0.2}, []float64{-0.4, -0.2, 0, 0.2, 0.4}},
+		{AxisScale{Min: 1, Max: 1000, Step: 10, Log: true}, []float64{1, 10, 100, 1000}},
+	}
+
+	for _, test := range tests {
+		ticks := test.scale.Ticks()
+		if len(ticks) != len(test.want) {
+			t.Errorf("%+v.Ticks() = %v, want %v", test.scale, ticks, test.want)
+			continue
+		}
+		for i := range ticks {
+			if math.Abs(ticks[i]-test.want[i]) > 1e-9 {
+				t.Errorf("%+v.Ticks() = %v, want %v", test.scale, ticks, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAxisScalePosition(t *testing.T) {
+
+	tests := []struct {
+		scale             AxisScale
+		value, start, end float64
+		want              float64
+	}{
+		{AxisScale{Min: 0, Max: 100, Step: 20}, 25, 0, 200, 50},
+		{AxisScale{Min: -50, Max: 50, Step: 25}, 0, 300, 100, 200},
+		{AxisScale{Min: 1, Max: 1000, Step: 10, Log: true}, 10, 0, 300, 100},
+		{AxisScale{Min: 1, Max: 1000, Step: 10, Log: true}, 0, 0, 300, 0},
+	}
+
+	for _, test := range tests {
+		if position := test.scale.Position(test.value, test.start, test.end); math.Abs(position-test.want) > 1e-9 {
+			t.Errorf("%+v.Position(%v, %v, %v) = %v, want %v", test.scale, test.value, test.start, test.end, position, test.want)
+		}
+	}
+}
+
+func TestAxisScaleTickLabel(t *testing.T) {
+
+	logScale := AxisScale{Min: 0.01, Max: 100, Step: 10, Log: true}
+	linearScale := AxisScale{Min: 0, Max: 1, Step: 0.25}
+
+	tests := []struct {
+		scale     AxisScale
+		tickValue float64
+		format    ValueFormat
+		want      string
+	}{
+		//Log ticks below 1 get the decimals they need, the rest keep the label format's decimals
+		{logScale, 0.01, ValueFormat{}, "0.01"},
+		{logScale, 0.1, ValueFormat{}, "0.1"},
+		{logScale, 0.1, ValueFormat{Decimals: 3}, "0.100"},
+		{logScale, 10, ValueFormat{}, "10"},
+		{logScale, 0.01, ValueFormat{Percentage: true}, "1%"},
+		{logScale, 0.001, ValueFormat{Percentage: true}, "0.1%"},
+		//Linear ticks are left to the label format
+		{linearScale, 0.25, ValueFormat{}, "0"},
+		{linearScale, 0.25, ValueFormat{Decimals: 2}, "0.25"},
+	}
+
+	for _, test := range tests {
+		if label := test.scale.TickLabel(test.tickValue, test.format); label != test.want {
+			t.Errorf("%+v.TickLabel(%v, %+v) = %q, want %q", test.scale, test.tickValue, test.format, label, test.want)
+		}
+	}
+}
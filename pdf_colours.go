@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Colours in the recipe can be written as {"R": 228, "G": 155, "B": 185, "A": 0.5}, "#E49BB9", "#E49BB980", "rgb(228,155,185)",
+//"rgba(228,155,185,0.5)" or a CSS colour name like "hotpink". The alpha is the opacity, from just above 0 (transparent) to 1 (opaque)
+func (colour *Colour) UnmarshalJSON(colourJSON []byte) (err error) {
+
+	var colourString string
+	if json.Unmarshal(colourJSON, &colourString) == nil {
+		parsedColour, err := ParseColour(colourString)
+		if err != nil {
+			return err
+		}
+		*colour = parsedColour
+		return nil
+	}
+
+	//The object form, the alpha is a pointer so that we can tell when it's been left out
+	var colourObject struct {
+		R int      `json: R`
+		G int      `json: G`
+		B int      `json: B`
+		A *float64 `json: A`
+	}
+	if err = json.Unmarshal(colourJSON, &colourObject); err != nil {
+		return fmt.Errorf("colour %s should be an object with R, G and B values or a colour string", string(colourJSON))
+	}
+
+	parsedColour := Colour{R: colourObject.R, G: colourObject.G, B: colourObject.B, A: 1.0}
+	if colourObject.A != nil {
+		parsedColour.A = *colourObject.A
+	}
+	if err = parsedColour.Validate(); err != nil {
+		return err
+	}
+	*colour = parsedColour
+
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Parsing a hex, rgb()/rgba() or CSS named colour string
+func ParseColour(colourString string) (colour Colour, err error) {
+
+	trimmedColour := strings.ToLower(strings.TrimSpace(colourString))
+	colour.A = 1.0
+
+	if namedColour, ok := cssColourNames[trimmedColour]; ok {
+		trimmedColour = namedColour
+	}
+
+	switch {
+
+	case strings.HasPrefix(trimmedColour, "#"):
+		hexDigits := trimmedColour[1:]
+		//The short forms, #RGB and #RGBA, double up each digit
+		if len(hexDigits) == 3 || len(hexDigits) == 4 {
+			expandedDigits := ""
+			for _, digit := range hexDigits {
+				expandedDigits += string(digit) + string(digit)
+			}
+			hexDigits = expandedDigits
+		}
+		if len(hexDigits) != 6 && len(hexDigits) != 8 {
+			return colour, fmt.Errorf("colour %q should be in the form #RRGGBB or #RRGGBBAA", colourString)
+		}
+		hexValue, parseErr := strconv.ParseUint(hexDigits, 16, 32)
+		if parseErr != nil {
+			return colour, fmt.Errorf("colour %q isn't a valid hex colour", colourString)
+		}
+		if len(hexDigits) == 8 {
+			colour.A = float64(hexValue&0xFF) / 255.0
+			hexValue = hexValue >> 8
+		}
+		colour.R = int(hexValue >> 16 & 0xFF)
+		colour.G = int(hexValue >> 8 & 0xFF)
+		colour.B = int(hexValue & 0xFF)
+
+	case strings.HasPrefix(trimmedColour, "rgb(") || strings.HasPrefix(trimmedColour, "rgba("):
+		if !strings.HasSuffix(trimmedColour, ")") {
+			return colour, fmt.Errorf("colour %q is missing its closing bracket", colourString)
+		}
+		isRGBA := strings.HasPrefix(trimmedColour, "rgba(")
+		components := strings.Split(trimmedColour[strings.Index(trimmedColour, "(")+1:len(trimmedColour)-1], ",")
+		if (isRGBA && len(components) != 4) || (!isRGBA && len(components) != 3) {
+			return colour, fmt.Errorf("colour %q has the wrong number of values", colourString)
+		}
+		rgbValues := make([]int, 3)
+		for i := range rgbValues {
+			if rgbValues[i], err = strconv.Atoi(strings.TrimSpace(components[i])); err != nil {
+				return colour, fmt.Errorf("colour %q has a value that isn't a whole number", colourString)
+			}
+		}
+		colour.R, colour.G, colour.B = rgbValues[0], rgbValues[1], rgbValues[2]
+		if isRGBA {
+			if colour.A, err = strconv.ParseFloat(strings.TrimSpace(components[3]), 64); err != nil {
+				return colour, fmt.Errorf("colour %q has an alpha that isn't a number", colourString)
+			}
+		}
+
+	default:
+		return colour, fmt.Errorf("colour %q isn't a hex colour, rgb()/rgba() colour or CSS colour name", colourString)
+	}
+
+	return colour, colour.Validate()
+}
+
+//Checking the colour channels are in the 0-255 range and the alpha is between 0 and 1. A fully transparent colour isn't allowed since
+//an alpha of 0 is how an unset colour looks, if something shouldn't be drawn then its style should be changed instead
+func (colour Colour) Validate() error {
+	for _, channel := range []int{colour.R, colour.G, colour.B} {
+		if channel < 0 || channel > 255 {
+			return fmt.Errorf("colour (%d, %d, %d) has a value outside the range 0-255", colour.R, colour.G, colour.B)
+		}
+	}
+	if colour.A <= 0.0 || colour.A > 1.0 {
+		return fmt.Errorf("colour (%d, %d, %d) has an alpha of %v, it should be above 0 and at most 1", colour.R, colour.G, colour.B, colour.A)
+	}
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Running a drawing function with the colour's alpha applied, then going back to opaque so later drawing isn't affected.
+//Colours that weren't set in the recipe have an alpha of 0 and are drawn opaque
+func DrawWithAlpha(pdf *gofpdf.Fpdf, colour Colour, draw func()) {
+	if colour.A <= 0.0 || colour.A >= 1.0 {
+		draw()
+		return
+	}
+	pdf.SetAlpha(colour.A, "Normal")
+	draw()
+	pdf.SetAlpha(1.0, "Normal")
+}
+
+//Filling a cell's box in the colour with its alpha applied. Cells draw their fill in the same call as their text and borders, so a
+//see-through fill is drawn here on its own first and false is returned to tell the cell not to fill itself, keeping its text opaque
+func FillCellWithAlpha(pdf *gofpdf.Fpdf, colour Colour, filled bool, x, y, width, height float64) bool {
+	if !filled || colour.A <= 0.0 || colour.A >= 1.0 {
+		return filled
+	}
+	pdf.SetFillColor(colour.R, colour.G, colour.B)
+	DrawWithAlpha(pdf, colour, func() {
+		pdf.Rect(x, y, width, height, "F")
+	})
+	return false
+}
+
+//The CSS named colours
+var cssColourNames = map[string]string{
+	"aliceblue":            "#f0f8ff",
+	"antiquewhite":         "#faebd7",
+	"aqua":                 "#00ffff",
+	"aquamarine":           "#7fffd4",
+	"azure":                "#f0ffff",
+	"beige":                "#f5f5dc",
+	"bisque":               "#ffe4c4",
+	"black":                "#000000",
+	"blanchedalmond":       "#ffebcd",
+	"blue":                 "#0000ff",
+	"blueviolet":           "#8a2be2",
+	"brown":                "#a52a2a",
+	"burlywood":            "#deb887",
+	"cadetblue":            "#5f9ea0",
+	"chartreuse":           "#7fff00",
+	"chocolate":            "#d2691e",
+	"coral":                "#ff7f50",
+	"cornflowerblue":       "#6495ed",
+	"cornsilk":             "#fff8dc",
+	"crimson":              "#dc143c",
+	"cyan":                 "#00ffff",
+	"darkblue":             "#00008b",
+	"darkcyan":             "#008b8b",
+	"darkgoldenrod":        "#b8860b",
+	"darkgray":             "#a9a9a9",
+	"darkgreen":            "#006400",
+	"darkgrey":             "#a9a9a9",
+	"darkkhaki":            "#bdb76b",
+	"darkmagenta":          "#8b008b",
+	"darkolivegreen":       "#556b2f",
+	"darkorange":           "#ff8c00",
+	"darkorchid":           "#9932cc",
+	"darkred":              "#8b0000",
+	"darksalmon":           "#e9967a",
+	"darkseagreen":         "#8fbc8f",
+	"darkslateblue":        "#483d8b",
+	"darkslategray":        "#2f4f4f",
+	"darkslategrey":        "#2f4f4f",
+	"darkturquoise":        "#00ced1",
+	"darkviolet":           "#9400d3",
+	"deeppink":             "#ff1493",
+	"deepskyblue":          "#00bfff",
+	"dimgray":              "#696969",
+	"dimgrey":              "#696969",
+	"dodgerblue":           "#1e90ff",
+	"firebrick":            "#b22222",
+	"floralwhite":          "#fffaf0",
+	"forestgreen":          "#228b22",
+	"fuchsia":              "#ff00ff",
+	"gainsboro":            "#dcdcdc",
+	"ghostwhite":           "#f8f8ff",
+	"gold":                 "#ffd700",
+	"goldenrod":            "#daa520",
+	"gray":                 "#808080",
+	"green":                "#008000",
+	"greenyellow":          "#adff2f",
+	"grey":                 "#808080",
+	"honeydew":             "#f0fff0",
+	"hotpink":              "#ff69b4",
+	"indianred":            "#cd5c5c",
+	"indigo":               "#4b0082",
+	"ivory":                "#fffff0",
+	"khaki":                "#f0e68c",
+	"lavender":             "#e6e6fa",
+	"lavenderblush":        "#fff0f5",
+	"lawngreen":            "#7cfc00",
+	"lemonchiffon":         "#fffacd",
+	"lightblue":            "#add8e6",
+	"lightcoral":           "#f08080",
+	"lightcyan":            "#e0ffff",
+	"lightgoldenrodyellow": "#fafad2",
+	"lightgray":            "#d3d3d3",
+	"lightgreen":           "#90ee90",
+	"lightgrey":            "#d3d3d3",
+	"lightpink":            "#ffb6c1",
+	"lightsalmon":          "#ffa07a",
+	"lightseagreen":        "#20b2aa",
+	"lightskyblue":         "#87cefa",
+	"lightslategray":       "#778899",
+	"lightslategrey":       "#778899",
+	"lightsteelblue":       "#b0c4de",
+	"lightyellow":          "#ffffe0",
+	"lime":                 "#00ff00",
+	"limegreen":            "#32cd32",
+	"linen":                "#faf0e6",
+	"magenta":              "#ff00ff",
+	"maroon":               "#800000",
+	"mediumaquamarine":     "#66cdaa",
+	"mediumblue":           "#0000cd",
+	"mediumorchid":         "#ba55d3",
+	"mediumpurple":         "#9370db",
+	"mediumseagreen":       "#3cb371",
+	"mediumslateblue":      "#7b68ee",
+	"mediumspringgreen":    "#00fa9a",
+	"mediumturquoise":      "#48d1cc",
+	"mediumvioletred":      "#c71585",
+	"midnightblue":         "#191970",
+	"mintcream":            "#f5fffa",
+	"mistyrose":            "#ffe4e1",
+	"moccasin":             "#ffe4b5",
+	"navajowhite":          "#ffdead",
+	"navy":                 "#000080",
+	"oldlace":              "#fdf5e6",
+	"olive":                "#808000",
+	"olivedrab":            "#6b8e23",
+	"orange":               "#ffa500",
+	"orangered":            "#ff4500",
+	"orchid":               "#da70d6",
+	"palegoldenrod":        "#eee8aa",
+	"palegreen":            "#98fb98",
+	"paleturquoise":        "#afeeee",
+	"palevioletred":        "#db7093",
+	"papayawhip":           "#ffefd5",
+	"peachpuff":            "#ffdab9",
+	"peru":                 "#cd853f",
+	"pink":                 "#ffc0cb",
+	"plum":                 "#dda0dd",
+	"powderblue":           "#b0e0e6",
+	"purple":               "#800080",
+	"rebeccapurple":        "#663399",
+	"red":                  "#ff0000",
+	"rosybrown":            "#bc8f8f",
+	"royalblue":            "#4169e1",
+	"saddlebrown":          "#8b4513",
+	"salmon":               "#fa8072",
+	"sandybrown":           "#f4a460",
+	"seagreen":             "#2e8b57",
+	"seashell":             "#fff5ee",
+	"sienna":               "#a0522d",
+	"silver":               "#c0c0c0",
+	"skyblue":              "#87ceeb",
+	"slateblue":            "#6a5acd",
+	"slategray":            "#708090",
+	"slategrey":            "#708090",
+	"snow":                 "#fffafa",
+	"springgreen":          "#00ff7f",
+	"steelblue":            "#4682b4",
+	"tan":                  "#d2b48c",
+	"teal":                 "#008080",
+	"thistle":              "#d8bfd8",
+	"tomato":               "#ff6347",
+	"turquoise":            "#40e0d0",
+	"violet":               "#ee82ee",
+	"wheat":                "#f5deb3",
+	"white":                "#ffffff",
+	"whitesmoke":           "#f5f5f5",
+	"yellow":               "#ffff00",
+	"yellowgreen":          "#9acd32",
+}
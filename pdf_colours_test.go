@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseColour(t *testing.T) {
+
+	tests := []struct {
+		colourString string
+		want         Colour
+	}{
+		{"#ff8000", Colour{R: 255, G: 128, B: 0, A: 1.0}},
+		{"#FF8000", Colour{R: 255, G: 128, B: 0, A: 1.0}},
+		{"#f80", Colour{R: 255, G: 136, B: 0, A: 1.0}},
+		{"#ff800080", Colour{R: 255, G: 128, B: 0, A: 128.0 / 255.0}},
+		{"#f808", Colour{R: 255, G: 136, B: 0, A: 136.0 / 255.0}},
+		{"rgb(10, 20, 30)", Colour{R: 10, G: 20, B: 30, A: 1.0}},
+		{"rgba(10,20,30,0.5)", Colour{R: 10, G: 20, B: 30, A: 0.5}},
+		{"  RebeccaPurple ", Colour{R: 102, G: 51, B: 153, A: 1.0}},
+		{"white", Colour{R: 255, G: 255, B: 255, A: 1.0}},
+	}
+
+	for _, test := range tests {
+		colour, err := ParseColour(test.colourString)
+		if err != nil {
+			t.Errorf("ParseColour(%q) returned an error: %v", test.colourString, err)
+			continue
+		}
+		if colour.R != test.want.R || colour.G != test.want.G || colour.B != test.want.B || math.Abs(colour.A-test.want.A) > 1e-9 {
+			t.Errorf("ParseColour(%q) = %+v, want %+v", test.colourString, colour, test.want)
+		}
+	}
+}
+
+func TestParseColourRejectsBadColours(t *testing.T) {
+
+	for _, colourString := range []string{
+		"",
+		"#ff80",
+		"#ff80001",
+		"#gggggg",
+		"rgb(10, 20)",
+		"rgb(10, 20, 30",
+		"rgba(10, 20, 30)",
+		"rgb(10.5, 20, 30)",
+		"rgb(300, 20, 30)",
+		"rgba(10, 20, 30, 0)",
+		"rgba(10, 20, 30, 1.5)",
+		"notacolour",
+	} {
+		if colour, err := ParseColour(colourString); err == nil {
+			t.Errorf("ParseColour(%q) = %+v, want an error", colourString, colour)
+		}
+	}
+}
+
+func TestColourUnmarshalJSON(t *testing.T) {
+
+	//Colours can be written as an object or as a string, objects without an alpha are opaque
+	var colours []Colour
+	if err := json.Unmarshal([]byte(`[{"R": 1, "G": 2, "B": 3}, "#010203", "rgba(1, 2, 3, 0.25)"]`), &colours); err != nil {
+		t.Fatalf("unmarshalling colours returned an error: %v", err)
+	}
+	want := []Colour{{R: 1, G: 2, B: 3, A: 1.0}, {R: 1, G: 2, B: 3, A: 1.0}, {R: 1, G: 2, B: 3, A: 0.25}}
+	for i := range want {
+		if colours[i] != want[i] {
+			t.Errorf("colour %d = %+v, want %+v", i, colours[i], want[i])
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`"#12345"`), &Colour{}); err == nil {
+		t.Errorf("unmarshalling a bad colour string didn't return an error")
+	}
+}
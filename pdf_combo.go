@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Combo charts show some series as bars and others as lines against the same categories. The bar series sit side by side in each
+//category and the line series run through the middle of the categories, with a marker at each point when the markers have a size.
+//Series on the secondary axis are scaled against their own y axis on the right of the chart. Annotations are drawn against the
+//primary axis, a callout points at the highest primary series value in its category and a computed line is the average, min or
+//max of those values
+//
+//  "itemType": "combo",
+//  "dataSource": "Sales", "dataSeriesCategory": "Month",
+//  "series": [{"key": "Units sold"}, {"key": "Average price", "type": "line", "axis": "secondary"}],
+//  "chartSettings": {"secondaryYAxis": {"labelFormat": {"currency": "£", "decimals": 2}, "title": {"text": "Price"}}}
+
+//The y axis on the right of a combo chart, for the series on the secondary axis. It has its own type, ticks, label format and title,
+//and it's scaled to its own series the same way as the primary axis
+type SecondaryAxis struct {
+	Type          string      `json: type`
+	NumberOfTicks float64     `json: numberOfTicks`
+	LabelFormat   ValueFormat `json: labelFormat`
+	Title         AxisTitle   `json: title`
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a combo chart's series and secondary axis
+func ValidateComboChart(comboItem PdfContentItem, series []ChartSeries) (err error) {
+
+	for _, chartSeries := range series {
+		if chartSeries.Type != "bar" && chartSeries.Type != "line" {
+			return fmt.Errorf("combo chart series %q has unsupported type %q, expected bar or line", chartSeries.Key, chartSeries.Type)
+		}
+		if chartSeries.Axis != "primary" && chartSeries.Axis != "secondary" {
+			return fmt.Errorf("combo chart series %q has unsupported axis %q, expected primary or secondary", chartSeries.Key, chartSeries.Axis)
+		}
+	}
+	if !valueAxisTypes[comboItem.ChartSettings.SecondaryYAxis.Type] {
+		return fmt.Errorf("unsupported secondary y axis type %q, expected linear or log", comboItem.ChartSettings.SecondaryYAxis.Type)
+	}
+	if comboItem.ChartSettings.SecondaryYAxis.NumberOfTicks < 2 {
+		return fmt.Errorf("combo chart for %q needs at least 2 secondary y axis ticks, got %v", comboItem.DataSource, comboItem.ChartSettings.SecondaryYAxis.NumberOfTicks)
+	}
+	if err = ValidateValueFormat(comboItem.ChartSettings.SecondaryYAxis.LabelFormat); err != nil {
+		return fmt.Errorf("secondary y axis label format: %v", err)
+	}
+
+	return ValidateDataLabels(comboItem.ChartSettings.DataLabels)
+}
+
+//Working out the scales for a combo chart's axes, each scaled to the values of the series on it. With every series on the secondary
+//axis there's nothing to scale the primary axis to, so it shares the secondary axis' scale and its gridlines line up with those ticks
+func ComboChartScales(chartSettings ChartSettings, primaryValues, secondaryValues []float64) (primaryScale, secondaryScale AxisScale, err error) {
+
+	if len(secondaryValues) > 0 {
+		minSecondaryValue, maxSecondaryValue := valueRange(secondaryValues)
+		secondaryScale, err = CategoryValueScale(chartSettings.SecondaryYAxis.Type, chartSettings.SecondaryYAxis.NumberOfTicks, minSecondaryValue, maxSecondaryValue)
+		if err != nil {
+			return primaryScale, secondaryScale, fmt.Errorf("secondary y axis: %v", err)
+		}
+		if len(primaryValues) == 0 {
+			return secondaryScale, secondaryScale, nil
+		}
+	}
+
+	minPrimaryValue, maxPrimaryValue := valueRange(primaryValues)
+	primaryScale, err = CategoryValueScale(chartSettings.YAxisType, chartSettings.NumberOfYAxisTicks, minPrimaryValue, maxPrimaryValue)
+
+	return primaryScale, secondaryScale, err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing combo charts
+func ProcessComboChartPDFItem(pdf *gofpdf.Fpdf, comboItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+
+	if err = ValidateCategoryChart(comboItem); err != nil {
+		return err
+	}
+	series, err := ChartSeriesForItem(comboItem)
+	if err != nil {
+		return err
+	}
+	if err = ValidateComboChart(comboItem, series); err != nil {
+		return err
+	}
+	chartSettings := comboItem.ChartSettings
+
+	for _, dataset := range data {
+		if comboItem.DataSource == dataset.DataSource {
+
+			var categoryValues []interface{}
+			var categories []string
+			for _, dataPoint := range dataset.DataPoints {
+				categoryValues = append(categoryValues, dataPoint.(map[string]interface{})[comboItem.DataSeriesCategory])
+				categories = append(categories, FormatCellValue(dataPoint.(map[string]interface{})[comboItem.DataSeriesCategory]))
+			}
+			if err = ValidateChartAnnotations(chartSettings.Annotations, categories); err != nil {
+				return err
+			}
+			seriesValues, err := chartSeriesValues(comboItem, series, dataset.DataPoints)
+			if err != nil {
+				return err
+			}
+
+			//Each axis is scaled to the values of the series on it
+			////The highest primary value in each category is kept for the annotations
+			var primaryValues, secondaryValues []float64
+			annotationValues := make([]float64, len(categoryValues))
+			hasPrimaryValue := make([]bool, len(categoryValues))
+			numberOfBars := 0
+			for seriesIndex, chartSeries := range series {
+				if chartSeries.Axis == "secondary" {
+					secondaryValues = append(secondaryValues, seriesValues[seriesIndex]...)
+				} else {
+					primaryValues = append(primaryValues, seriesValues[seriesIndex]...)
+					for categoryIndex, value := range seriesValues[seriesIndex] {
+						if !hasPrimaryValue[categoryIndex] || value > annotationValues[categoryIndex] {
+							annotationValues[categoryIndex] = value
+							hasPrimaryValue[categoryIndex] = true
+						}
+					}
+				}
+				if chartSeries.Type == "bar" {
+					numberOfBars++
+				}
+			}
+			hasSecondaryAxis := len(secondaryValues) > 0
+
+			primaryScale, secondaryScale, err := ComboChartScales(chartSettings, primaryValues, secondaryValues)
+			if err != nil {
+				return err
+			}
+			plotArea, err := CategoryPlotArea(comboItem, pdfSettings, categoryValues, primaryScale)
+			if err != nil {
+				return err
+			}
+			secondaryPlotArea := plotArea
+			secondaryPlotArea.ValueScale = secondaryScale
+
+			DrawChartBackground(pdf, chartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, comboItem.Width, comboItem.Height)
+			DrawCategoryChartValueAxis(pdf, chartSettings, plotArea)
+			if hasSecondaryAxis {
+				DrawSecondaryValueAxis(pdf, chartSettings, secondaryPlotArea)
+			}
+			DrawChartBands(pdf, chartSettings.Annotations, plotArea)
+
+			//Drawing the bars first so that the lines are on top of them. The bar series share each category between them
+			pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+			barWidth := 0.0
+			if numberOfBars > 0 {
+				barWidth = (plotArea.CategoryWidth - 2*chartSettings.GapBetweenBars) / float64(numberOfBars)
+			}
+			barIndex := 0
+			for seriesIndex, chartSeries := range series {
+				if chartSeries.Type != "bar" {
+					continue
+				}
+				seriesPlotArea := plotArea
+				if chartSeries.Axis == "secondary" {
+					seriesPlotArea = secondaryPlotArea
+				}
+				colour := *chartSeries.Colour
+				for categoryIndex, value := range seriesValues[seriesIndex] {
+					barXPosition := plotArea.CategoryCentre(categoryIndex) - 0.5*plotArea.CategoryWidth + chartSettings.GapBetweenBars + float64(barIndex)*barWidth
+					barHeight := plotArea.Bottom - seriesPlotArea.YPosition(value)
+
+					pdf.SetFillColor(colour.R, colour.G, colour.B)
+					pdf.SetDrawColor(chartSettings.SeriesFormat.BorderColour.R, chartSettings.SeriesFormat.BorderColour.G, chartSettings.SeriesFormat.BorderColour.B)
+					DrawWithAlpha(pdf, colour, func() {
+						pdf.Rect(barXPosition, plotArea.Bottom, barWidth, -barHeight, chartSettings.SeriesFormat.Style)
+					})
+					if chartSettings.DataLabels.Show {
+						DrawBarDataLabel(pdf, chartSettings.DataLabels, value, barXPosition, barWidth, plotArea.Bottom, barHeight, plotArea.Top)
+						pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+					}
+				}
+				barIndex++
+			}
+
+			for seriesIndex, chartSeries := range series {
+				if chartSeries.Type != "line" {
+					continue
+				}
+				seriesPlotArea := plotArea
+				if chartSeries.Axis == "secondary" {
+					seriesPlotArea = secondaryPlotArea
+				}
+				DrawSeriesLine(pdf, chartSettings, *chartSeries.Colour, seriesValues[seriesIndex], seriesPlotArea)
+			}
+
+			DrawCategoryChartCategoryAxis(pdf, chartSettings, plotArea, categoryValues)
+			DrawChartAnnotations(pdf, chartSettings.Annotations, plotArea, categories, annotationValues)
+			DrawChartLegend(pdf, chartSettings.Legend, series, plotArea)
+
+			//Adding the chart and axis titles
+			DrawChartTitle(pdf, chartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, comboItem.Width)
+			DrawXAxisTitle(pdf, chartSettings.XAxisTitle, plotArea.ChartBoxX, comboItem.Width, plotArea.ChartBoxY+comboItem.Height)
+			DrawYAxisTitle(pdf, chartSettings.YAxisTitle, plotArea.ChartBoxX, plotArea.Top, plotArea.Bottom)
+			if hasSecondaryAxis {
+				DrawSecondaryYAxisTitle(pdf, chartSettings.SecondaryYAxis.Title, plotArea.ChartBoxX+comboItem.Width, plotArea.Top, plotArea.Bottom)
+			}
+		}
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing a series as a line through the middle of its categories in the series format's line width, with the chart's markers on it
+func DrawSeriesLine(pdf *gofpdf.Fpdf, chartSettings ChartSettings, colour Colour, values []float64, plotArea ChartPlotArea) {
+
+	pdf.SetLineWidth(chartSettings.SeriesFormat.LineWidth)
+	pdf.SetDrawColor(colour.R, colour.G, colour.B)
+	for categoryIndex := 1; categoryIndex < len(values); categoryIndex++ {
+		pdf.Line(plotArea.CategoryCentre(categoryIndex-1), plotArea.YPosition(values[categoryIndex-1]), plotArea.CategoryCentre(categoryIndex), plotArea.YPosition(values[categoryIndex]))
+	}
+
+	markerFormat := ShapeStyle{Style: "FD", FillColour: colour, BorderColour: colour}
+	for categoryIndex, value := range values {
+		DrawMarker(pdf, chartSettings.Markers.Shape, markerFormat, plotArea.CategoryCentre(categoryIndex), plotArea.YPosition(value), chartSettings.Markers.Size)
+	}
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the secondary y axis on the right of the plot area with its ticks and labels. It doesn't have gridlines, they'd cross the
+//primary axis' gridlines
+func DrawSecondaryValueAxis(pdf *gofpdf.Fpdf, chartSettings ChartSettings, plotArea ChartPlotArea) {
+
+	font := chartSettings.ChartTextFont
+	secondaryAxis := chartSettings.SecondaryYAxis
+	tickLength := chartSettings.TickMarkLength
+
+	pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+	pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+	pdf.Line(plotArea.Right, plotArea.Top, plotArea.Right, plotArea.Bottom)
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	yAxisTitleWidth := YAxisTitleWidth(secondaryAxis.Title)
+	for _, tickValue := range plotArea.ValueScale.Ticks() {
+		tickYPosition := plotArea.YPosition(tickValue)
+		pdf.Line(plotArea.Right, tickYPosition, plotArea.Right+tickLength, tickYPosition)
+		pdf.SetXY(plotArea.Right+tickLength, tickYPosition-(0.5*font.Size))
+		pdf.CellFormat(chartSettings.DistanceFromSidesOfChartArea-tickLength-yAxisTitleWidth, font.Size, TextForFont(pdf, font.Family, plotArea.ValueScale.TickLabel(tickValue, secondaryAxis.LabelFormat)), "", 0, "LM", false, 0, "")
+	}
+}
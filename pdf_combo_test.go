@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestComboChartScales(t *testing.T) {
+
+	chartSettings := ChartSettings{YAxisType: "log", NumberOfYAxisTicks: 5, SecondaryYAxis: SecondaryAxis{Type: "linear", NumberOfTicks: 5}}
+
+	primaryScale, secondaryScale, err := ComboChartScales(chartSettings, []float64{3, 450}, []float64{20, 75})
+	if err != nil {
+		t.Fatalf("ComboChartScales returned an error: %v", err)
+	}
+	if !primaryScale.Log || primaryScale.Min != 1 || primaryScale.Max != 1000 {
+		t.Errorf("ComboChartScales primary scale = %+v, want a log scale from 1 to 1000", primaryScale)
+	}
+	if secondaryScale.Log || secondaryScale.Max < 75 {
+		t.Errorf("ComboChartScales secondary scale = %+v, want a linear scale up past 75", secondaryScale)
+	}
+
+	//With every series on the secondary axis the log primary axis isn't scaled to nothing, it shares the secondary scale
+	primaryScale, secondaryScale, err = ComboChartScales(chartSettings, nil, []float64{20, 75})
+	if err != nil {
+		t.Fatalf("ComboChartScales with no primary series returned an error: %v", err)
+	}
+	if primaryScale != secondaryScale {
+		t.Errorf("ComboChartScales with no primary series = %+v and %+v, want the primary axis to share the secondary scale", primaryScale, secondaryScale)
+	}
+
+	chartSettings.SecondaryYAxis.Type = "log"
+	if _, _, err := ComboChartScales(chartSettings, []float64{3, 450}, []float64{0, 75}); err == nil {
+		t.Errorf("ComboChartScales didn't return an error for a 0 on a log secondary axis")
+	}
+}
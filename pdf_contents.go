@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//An item marked with "heading": true in the recipe and where it ended up in the pdf. The text is the item's bookmark text if it has one,
+//otherwise its text or chart title, and the level comes from its bookmark
+type HeadingLocation struct {
+	Text      string
+	Level     int
+	Page      int
+	YPosition float64
+}
+
+//////////////////////////////////////////////////////////////////////
+//Listing the headings in the recipe in order, their pages are filled in as the items are laid out
+func CollectHeadingsFromRecipe(recipeFile PdfFields) (headings []HeadingLocation) {
+
+	for _, item := range recipeFile.PdfContents {
+		if !item.Heading {
+			continue
+		}
+		headingText := item.Bookmark.Text
+		if headingText == "" {
+			headingText = item.Text
+		}
+		if headingText == "" {
+			headingText = item.ChartSettings.ChartTitle.Text
+		}
+		headings = append(headings, HeadingLocation{Text: headingText, Level: item.Bookmark.Level})
+	}
+
+	return headings
+}
+
+//Checking whether the recipe needs the extra layout pass to find the heading page numbers
+func RecipeHasTableOfContents(recipeFile PdfFields) bool {
+	for _, item := range recipeFile.PdfContents {
+		if item.ItemType == "tableOfContents" {
+			return true
+		}
+	}
+	return false
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing a table of contents. Each heading gets a row with dot leaders up to its page number, and the row links to the heading.
+//The item's text, if it has any, is written above the rows in the header font
+func ProcessTableOfContentsPDFItem(pdf *gofpdf.Fpdf, tocItem PdfContentItem, pdfFields PdfFields, headings []HeadingLocation) (err error) {
+
+	font := tocItem.Font
+
+	//Settings the x and y position for the contents, and making position 0 equivalent to the margin that we've set
+	getXPosition := tocItem.XPosition + pdfFields.PdfSettings.PageLeftAndRightMargins
+	getYPosition := tocItem.YPosition + pdfFields.PdfSettings.PageTopMargin
+
+	//Without a width the contents stretch across the page between the margins
+	tocWidth := tocItem.Width
+	if tocWidth <= 0.0 {
+		pageWidth, _ := pdf.GetPageSize()
+		leftMargin, _, rightMargin, _ := pdf.GetMargins()
+		tocWidth = pageWidth - rightMargin - getXPosition
+		if tocWidth <= 0.0 {
+			tocWidth = pageWidth - leftMargin - rightMargin
+		}
+	}
+
+	cumulativeTocHeight := 0.0
+
+	//Title row
+	if tocItem.Text != "" {
+		pdf.SetFont(font.HeaderFont.Family, font.HeaderFont.Style, font.HeaderFont.Size)
+		pdf.SetTextColor(font.HeaderFont.Colour.R, font.HeaderFont.Colour.G, font.HeaderFont.Colour.B)
+		pdf.SetFillColor(font.HeaderFont.CellFill.Colour.R, font.HeaderFont.CellFill.Colour.G, font.HeaderFont.CellFill.Colour.B)
+		pdf.SetDrawColor(font.HeaderFont.CellBorders.Colour.R, font.HeaderFont.CellBorders.Colour.G, font.HeaderFont.CellBorders.Colour.B)
+		pdf.SetXY(getXPosition, getYPosition)
+		pdf.CellFormat(tocWidth, font.HeaderFont.Size+font.HeaderFont.LineSpacing, tocItem.Text, font.HeaderFont.CellBorders.Style, 0, font.HeaderFont.Alignment, font.HeaderFont.CellFill.Filled, 0, "")
+
+		getYPosition = getYPosition + font.HeaderFont.Size + font.HeaderFont.LineSpacing
+		cumulativeTocHeight = cumulativeTocHeight + font.HeaderFont.Size + font.HeaderFont.LineSpacing
+	}
+
+	//Row formatting
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+
+	rowHeight := font.Size + font.LineSpacing
+	dotWidth := pdf.GetStringWidth(".")
+	//Every level down is indented by the width of a couple of characters
+	levelIndent := 2.0 * pdf.GetStringWidth("0")
+
+	for _, heading := range headings {
+
+		//If the contents get larger than the height set in the recipe, stop adding rows. A height of 0 means there's no limit
+		if tocItem.Height > 0.0 && cumulativeTocHeight+rowHeight > tocItem.Height {
+			pdf.SetXY(getXPosition, getYPosition)
+			pdf.CellFormat(tocWidth, 0.5*rowHeight, "...", "", 0, "CB", false, 0, "")
+			break
+		}
+
+		rowXPosition := getXPosition + float64(heading.Level)*levelIndent
+		rowWidth := tocWidth - float64(heading.Level)*levelIndent
+		pageNumber := strconv.Itoa(heading.Page)
+
+		//Filling the gap between the heading and its page number with dots, leaving room for the cell margins either side
+		gapWidth := rowWidth - pdf.GetStringWidth(heading.Text+" ") - pdf.GetStringWidth(" "+pageNumber) - 2*pdf.GetCellMargin()
+		dotLeaders := ""
+		if gapWidth > 0.0 {
+			dotLeaders = strings.Repeat(".", int(math.Floor(gapWidth/dotWidth)))
+		}
+
+		pdf.SetXY(rowXPosition, getYPosition)
+		pdf.CellFormat(rowWidth, rowHeight, heading.Text+" "+dotLeaders, font.CellBorders.Style, 0, "LM", font.CellFill.Filled, 0, "")
+		pdf.SetXY(rowXPosition, getYPosition)
+		pdf.CellFormat(rowWidth, rowHeight, " "+pageNumber, "", 0, "RM", false, 0, "")
+
+		//Linking the whole row to the top of the heading's item
+		if heading.Page > 0 {
+			headingLink := pdf.AddLink()
+			pdf.SetLink(headingLink, heading.YPosition, heading.Page)
+			pdf.Link(rowXPosition, getYPosition, rowWidth, rowHeight, headingLink)
+		}
+
+		getYPosition = getYPosition + rowHeight
+		cumulativeTocHeight = cumulativeTocHeight + rowHeight
+	}
+
+	return err
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/jung-kurt/gofpdf"
 )
@@ -17,60 +23,107 @@ type Data []struct {
 
 //Chart settings
 type ChartSettings struct {
-	WatermarkFormat               ShapeStyle `json: watermarkFormat`
-	SeriesFormat                  ShapeStyle `json: seriesFormat`
-	AxisFormat                    ShapeStyle `json: axisFormat`
-	ChartTextFont                 Font       `json: chartTextFont`
-	ChartTitle                    ChartTitle `json: chartTitle`
-	DistanceFromTopOfChartArea    float64    `json: distanceFromTopOfChartArea`
-	DistanceFromBottomOfChartArea float64    `json: distanceFromBottomOfChartArea`
-	DistanceFromSidesOfChartArea  float64    `json: distanceFromSidesOfChartArea`
-	NumberOfYAxisTicks            float64    `json: numberOfYAxisTicks`
-	GapBetweenBars                float64    `json: gapBetweenBars`
-	TickMarkLength                float64    `json: tickMarkLength`
+	WatermarkFormat               ShapeStyle        `json: watermarkFormat`
+	SeriesFormat                  ShapeStyle        `json: seriesFormat`
+	AxisFormat                    ShapeStyle        `json: axisFormat`
+	ChartTextFont                 Font              `json: chartTextFont`
+	ChartTitle                    ChartTitle        `json: chartTitle`
+	DistanceFromTopOfChartArea    float64           `json: distanceFromTopOfChartArea`
+	DistanceFromBottomOfChartArea float64           `json: distanceFromBottomOfChartArea`
+	DistanceFromSidesOfChartArea  float64           `json: distanceFromSidesOfChartArea`
+	NumberOfYAxisTicks            float64           `json: numberOfYAxisTicks`
+	GapBetweenBars                float64           `json: gapBetweenBars`
+	TickMarkLength                float64           `json: tickMarkLength`
+	XAxisLabelFormat              ValueFormat       `json: xAxisLabelFormat`
+	YAxisLabelFormat              ValueFormat       `json: yAxisLabelFormat`
+	DataLabels                    DataLabels        `json: dataLabels`
+	HorizontalGridlines           Gridlines         `json: horizontalGridlines`
+	VerticalGridlines             Gridlines         `json: verticalGridlines`
+	XAxisTitle                    AxisTitle         `json: xAxisTitle`
+	YAxisTitle                    AxisTitle         `json: yAxisTitle`
+	XAxisLabelFit                 string            `json: xAxisLabelFit`
+	XAxisType                     string            `json: xAxisType`
+	YAxisType                     string            `json: yAxisType`
+	Annotations                   []ChartAnnotation `json: annotations`
+	NumberOfXAxisTicks            float64           `json: numberOfXAxisTicks`
+	Markers                       Markers           `json: markers`
+	PointLabels                   PointLabels       `json: pointLabels`
+	TrendLine                     TrendLine         `json: trendLine`
+	Palette                       []Colour          `json: palette`
+	Legend                        Legend            `json: legend`
+	SecondaryYAxis                SecondaryAxis     `json: secondaryYAxis`
 }
 
 //Mapping the fields from the recipe - that describes how the pdf is built and its contents
 type PdfFields struct {
 	PdfSettings struct {
-		PageOrientation         string  `json: pageOrientation`
-		PageUnits               string  `json: pageUnits`
-		PdfName                 string  `json: pdfName`
-		PdfLocation             string  `json: pdfLocation`
-		PageHeight              float64 `json: pageHeight`
-		PageWidth               float64 `json: pageWidth`
-		PageLeftAndRightMargins float64 `json: pageLeftAndRightMargins`
-		PageTopMargin           float64 `json: pageTopMargin`
-		Watermark               Colour  `json: watermark`
+		PageOrientation         string   `json: pageOrientation`
+		PageUnits               string   `json: pageUnits`
+		PageSize                string   `json: pageSize`
+		PdfName                 string   `json: pdfName`
+		PdfLocation             string   `json: pdfLocation`
+		Output                  string   `json: output`
+		CreateDirectories       bool     `json: createDirectories`
+		PageHeight              float64  `json: pageHeight`
+		PageWidth               float64  `json: pageWidth`
+		PageLeftAndRightMargins float64  `json: pageLeftAndRightMargins`
+		PageRightMargin         *float64 `json: pageRightMargin`
+		PageTopMargin           float64  `json: pageTopMargin`
+		PageBottomMargin        float64  `json: pageBottomMargin`
+		Watermark               *Colour  `json: watermark`
 	} `json: pdfSettings`
+	Metadata    Metadata         `json: metadata`
+	Fonts       []FontFile       `json: fonts`
 	PdfContents []PdfContentItem `json: pdfContents`
 }
 
 //Refers to the pdf items that are written to the pages. Examples would be tables, vertical bar charts etc
 type PdfContentItem struct {
-	ItemType           string        `json: itemType`
-	Text               string        `json: text`
-	DataSource         string        `json dataSource`
-	DataSeries         string        `json: dataSeries`
-	DataSeriesCategory string        `json: dataSeriesCategory`
-	XPosition          float64       `json: xPosition`
-	YPosition          float64       `json: yPosition`
-	Width              float64       `json: width`
-	Height             float64       `json: height`
-	Font               Font          `json: font`
-	ChartSettings      ChartSettings `json: chartSettings`
+	ItemType           string            `json: itemType`
+	Text               string            `json: text`
+	DataSource         string            `json dataSource`
+	DataSeries         string            `json: dataSeries`
+	DataSeriesCategory string            `json: dataSeriesCategory`
+	XPosition          float64           `json: xPosition`
+	YPosition          float64           `json: yPosition`
+	Width              float64           `json: width`
+	Height             float64           `json: height`
+	Font               Font              `json: font`
+	ChartSettings      ChartSettings     `json: chartSettings`
+	Bookmark           Bookmark          `json: bookmark`
+	Heading            bool              `json: heading`
+	Anchor             string            `json: anchor`
+	Columns            []TableColumn     `json: columns`
+	Markup             string            `json: markup`
+	ListSettings       ListSettings      `json: listSettings`
+	ListItems          []ListEntry       `json: listItems`
+	Overflow           string            `json: overflow`
+	TableSettings      TableSettings     `json: tableSettings`
+	XKey               string            `json: xKey`
+	YKey               string            `json: yKey`
+	SizeKey            string            `json: sizeKey`
+	LabelKey           string            `json: labelKey`
+	Series             []ChartSeries     `json: series`
+	SparklineSettings  SparklineSettings `json: sparklineSettings`
+	RowKey             string            `json: rowKey`
+	ColumnKey          string            `json: columnKey`
+	ValueKey           string            `json: valueKey`
+	HeatmapSettings    HeatmapSettings   `json: heatmapSettings`
 }
 
 type Font struct {
-	Colour      Colour      `json: colour`
-	Style       string      `json: style`
-	Size        float64     `json: size`
-	Family      string      `json: family`
-	Alignment   string      `json: alignment`
-	LineSpacing float64     `json: lineSpacing`
-	CellBorders CellBorders `json: cellBorders`
-	CellFill    CellFill    `json: cellFill`
-	HeaderFont  HeaderFont  `json: headerFont`
+	Colour          Colour      `json: colour`
+	Style           string      `json: style`
+	Size            float64     `json: size`
+	Family          string      `json: family`
+	Alignment       string      `json: alignment`
+	LineSpacing     float64     `json: lineSpacing`
+	CellBorders     CellBorders `json: cellBorders`
+	CellFill        CellFill    `json: cellFill`
+	HeaderFont      HeaderFont  `json: headerFont`
+	LinkColour      Colour      `json: linkColour`
+	FooterFont      HeaderFont  `json: footerFont`
+	GroupHeaderFont HeaderFont  `json: groupHeaderFont`
 }
 
 type HeaderFont struct {
@@ -84,6 +137,24 @@ type HeaderFont struct {
 	CellFill    CellFill    `json: cellFill`
 }
 
+//A column in a table. Without any columns a table shows the dataSeriesCategory and dataSeries fields. Columns without a width share
+//whatever's left of the table's width. linkKey is a field in the data holding each row's link for the cell, a URL or #anchor.
+//Rules and heatMap format the column's cells by their values, see pdf_tablestyles.go. Aggregate is what the column shows in
+//subtotal and total rows, see pdf_tabletotals.go. Format is how the column's values are written, see pdf_formats.go. A column with
+//the sparkline type draws its values in each cell instead of writing them, see pdf_sparklines.go
+type TableColumn struct {
+	Key       string            `json: key`
+	Header    string            `json: header`
+	Width     float64           `json: width`
+	LinkKey   string            `json: linkKey`
+	Rules     []CellRule        `json: rules`
+	HeatMap   *HeatMap          `json: heatMap`
+	Aggregate string            `json: aggregate`
+	Format    ValueFormat       `json: format`
+	Type      string            `json: type`
+	Sparkline SparklineSettings `json: sparkline`
+}
+
 type CellBorders struct {
 	Style  string `json: style`
 	Colour Colour `json: colour`
@@ -94,12 +165,14 @@ type CellFill struct {
 	Colour Colour `json: colour`
 }
 
+//DashPattern is the lengths of the dashes and gaps for dashed lines, like [2, 1], a solid line when it's empty
 type ShapeStyle struct {
-	Style        string  `json: style`
-	FillColour   Colour  `json: fillColour`
-	BorderColour Colour  `json: borderColour`
-	LineWidth    float64 `json: lineWidth`
-	LineColour   Colour  `json: lineColour`
+	Style        string    `json: style`
+	FillColour   Colour    `json: fillColour`
+	BorderColour Colour    `json: borderColour`
+	LineWidth    float64   `json: lineWidth`
+	LineColour   Colour    `json: lineColour`
+	DashPattern  []float64 `json: dashPattern`
 }
 
 type ChartTitle struct {
@@ -108,95 +181,262 @@ type ChartTitle struct {
 	Font                       Font    `json: font`
 }
 
+//Colours can also be written as hex, rgb() or CSS colour name strings, see UnmarshalJSON. A is the opacity, between 0 and 1
 type Colour struct {
-	R int `json: R`
-	G int `json: G`
-	B int `json: B`
+	R int     `json: R`
+	G int     `json: G`
+	B int     `json: B`
+	A float64 `json: A`
 }
 
+//Where the progress and error messages are printed
+var logOutput io.Writer = os.Stdout
+
 func main() {
 
+	//Running as an HTTP rendering service rather than building the pdf from the files on disk
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := Serve(os.Args[2:]); err != nil {
+			fmt.Fprintln(logOutput, "ERROR -->", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	//The recipe file will be interpreted and assigned to the below variable based on the pdfFields struct, described above
-	var pdfRecipeFromJSON PdfFields
+	//Any theme and named styles referenced in the recipe are resolved as it's loaded
 	pdfRecipe, err := ioutil.ReadFile("pdf_recipe.json")
 	if err != nil {
-		fmt.Println("ERROR --> ", err)
+		fmt.Fprintln(logOutput, "ERROR --> ", err)
+	}
+	pdfRecipeFromJSON, err := LoadRecipe(pdfRecipe)
+	if err != nil {
+		fmt.Fprintln(logOutput, "ERROR --> ", err)
+	}
+
+	//When the pdf itself is going to standard output, the progress messages move out of its way
+	if pdfRecipeFromJSON.PdfSettings.Output == "stdout" {
+		logOutput = os.Stderr
 	}
-	json.Unmarshal([]byte(pdfRecipe), &pdfRecipeFromJSON)
 
 	//Data file contains plotting and table data in an interface. To plot the data you specify the keys in the recipe, then the data file is searched for the values with that key
 	var data Data
 	dataToPull, err := ioutil.ReadFile("data.json")
 	if err != nil {
-		fmt.Println("ERROR --> ", err)
+		fmt.Fprintln(logOutput, "ERROR --> ", err)
 	}
 	json.Unmarshal([]byte(dataToPull), &data)
 
 	//The recipe's contents will be used to initialise the PDF, with the pdfSettings property dictating settings for the PDF, like the page orientation and margin sizes
 	pdf, err := InitialisePDF(pdfRecipeFromJSON)
 	if err != nil {
-		fmt.Println("ERROR -->", err)
+		fmt.Fprintln(logOutput, "ERROR -->", err)
 	}
 
 	//Adding items to the pdf and processing them depending on their type (tables, vertical bar charts etc)
-	ProcessPDFContentsItems(pdf, pdfRecipeFromJSON, data)
+	err = ProcessPDFContentsItems(pdf, pdfRecipeFromJSON, data)
+	if err != nil {
+		fmt.Fprintln(logOutput, "ERROR -->", err)
+	}
 
 	//Recipe "pdfSettings" property is scanned for the location to save the PDF to, and for the file name that we're saving the PDF as
 	err = SavePDF(pdfRecipeFromJSON, pdf)
 	if err != nil {
-		fmt.Println("ERROR -->", err)
+		fmt.Fprintln(logOutput, "ERROR -->", err)
 	} else {
-		fmt.Println()
+		fmt.Fprintln(logOutput)
 	}
 
 }
 
 //////////////////////////////////////////////////////////////////////
 // Parsing the recipes based on the itemType
-func ProcessPDFContentsItems(pdf *gofpdf.Fpdf, contentsToProcessFromRecipe PdfFields, dataset Data) {
+//An item that fails doesn't stop the rest from being processed, the errors from all of the failed items are returned together
+func ProcessPDFContentsItems(pdf *gofpdf.Fpdf, contentsToProcessFromRecipe PdfFields, dataset Data) (err error) {
+
+	//The page each heading lands on is only known once everything has been laid out, so when there's a table of contents the
+	//items are laid out on a scratch pdf first to find the pages, then drawn for real with the page numbers filled in
+	//The layout pass's item errors are left for the real pass to report, since it draws the same items
+	headings := CollectHeadingsFromRecipe(contentsToProcessFromRecipe)
+	if RecipeHasTableOfContents(contentsToProcessFromRecipe) {
+		layoutPDF, layoutErr := InitialisePDF(contentsToProcessFromRecipe)
+		if layoutErr != nil {
+			return fmt.Errorf("laying out the table of contents: %v", layoutErr)
+		}
+		processPDFContentsItems(layoutPDF, contentsToProcessFromRecipe, dataset, headings, ioutil.Discard)
+	}
+
+	return processPDFContentsItems(pdf, contentsToProcessFromRecipe, dataset, headings, logOutput)
+}
+
+//One pass over the items, recording where each heading lands as it goes
+func processPDFContentsItems(pdf *gofpdf.Fpdf, contentsToProcessFromRecipe PdfFields, dataset Data, headings []HeadingLocation, itemLog io.Writer) (err error) {
 
-	for _, itemToProcess := range contentsToProcessFromRecipe.PdfContents {
+	var itemErrors []string
+	headingIndex := 0
+	anchors := NewPdfAnchors()
+
+	//An item that doesn't fit where it's put is moved to the top of the next page by the automatic page break. That's seen as a page
+	//break before anything of the item has been drawn, while the position is still at the top of the item
+	var itemYPosition float64
+	itemBrokePage, itemMovedToNextPage := false, false
+	pdf.SetAcceptPageBreakFunc(func() bool {
+		if !itemBrokePage && pdf.GetY() == itemYPosition {
+			itemMovedToNextPage = true
+		}
+		itemBrokePage = true
+		autoPageBreak, _ := pdf.GetAutoPageBreak()
+		return autoPageBreak
+	})
+
+	for i, itemToProcess := range contentsToProcessFromRecipe.PdfContents {
+
+		var itemErr error
+		itemYPosition = itemToProcess.YPosition + contentsToProcessFromRecipe.PdfSettings.PageTopMargin
+		itemPage := pdf.PageNo()
+		itemBrokePage, itemMovedToNextPage = false, false
 
 		//For each itemType in the pdfContents array in the recipe file, process each recipe depending on the itemType
 		switch itemToProcess.ItemType {
 
 		case "textBlock":
 
-			fmt.Println("Found textblock | Text --> ", itemToProcess.Text)
-			err := ProcessTextBlockPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe)
-			if err != nil {
-				fmt.Println(err)
-			}
+			fmt.Fprintln(itemLog, "Found textblock | Text --> ", itemToProcess.Text)
+			itemErr = ProcessTextBlockPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, anchors)
 
 		case "table":
 
-			fmt.Println("Found table || Data Source --> ", itemToProcess.DataSource, "-*- Data series --> ", itemToProcess.DataSeries)
-			err := ProcessTablePDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
-			if err != nil {
-				fmt.Println(err)
-			}
+			fmt.Fprintln(itemLog, "Found table || Data Source --> ", itemToProcess.DataSource, "-*- Data series --> ", itemToProcess.DataSeries)
+			itemErr = ProcessTablePDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset, anchors)
 
 		case "verticalBar":
 
-			fmt.Println("Found vertical bar chart || Data Source --> ", itemToProcess.DataSource, "-*- Data series --> ", itemToProcess.DataSeries)
-			err := ProcessVerticalBarChartPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
-			if err != nil {
-				fmt.Println(err)
-			}
+			fmt.Fprintln(itemLog, "Found vertical bar chart || Data Source --> ", itemToProcess.DataSource, "-*- Data series --> ", itemToProcess.DataSeries)
+			itemErr = ProcessVerticalBarChartPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "scatter", "bubble":
+
+			fmt.Fprintln(itemLog, "Found", itemToProcess.ItemType, "chart || Data Source --> ", itemToProcess.DataSource, "-*- X --> ", itemToProcess.XKey, "-*- Y --> ", itemToProcess.YKey)
+			itemErr = ProcessScatterChartPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "area", "stackedArea":
+
+			fmt.Fprintln(itemLog, "Found", itemToProcess.ItemType, "chart || Data Source --> ", itemToProcess.DataSource, "-*- Series --> ", len(itemToProcess.Series))
+			itemErr = ProcessAreaChartPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "combo":
+
+			fmt.Fprintln(itemLog, "Found combo chart || Data Source --> ", itemToProcess.DataSource, "-*- Series --> ", len(itemToProcess.Series))
+			itemErr = ProcessComboChartPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "sparkline":
+
+			fmt.Fprintln(itemLog, "Found sparkline || Data Source --> ", itemToProcess.DataSource, "-*- Data series --> ", itemToProcess.DataSeries)
+			itemErr = ProcessSparklinePDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "heatmap":
+
+			fmt.Fprintln(itemLog, "Found heatmap || Data Source --> ", itemToProcess.DataSource, "-*- Rows --> ", itemToProcess.RowKey, "-*- Columns --> ", itemToProcess.ColumnKey)
+			itemErr = ProcessHeatmapPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset)
+
+		case "list":
+
+			fmt.Fprintln(itemLog, "Found list || Data Source --> ", itemToProcess.DataSource, "-*- Entries --> ", len(itemToProcess.ListItems))
+			itemErr = ProcessListPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, dataset, anchors)
+
+		case "tableOfContents":
+
+			fmt.Fprintln(itemLog, "Found table of contents || Headings --> ", len(headings))
+			itemErr = ProcessTableOfContentsPDFItem(pdf, itemToProcess, contentsToProcessFromRecipe, headings)
+
+		case "pageBreak":
+
+			//Items after the page break are positioned from the top of the new page
+			fmt.Fprintln(itemLog, "Found page break")
+			pdf.AddPage()
+
+		default:
+
+			itemErr = fmt.Errorf("unknown item type %q", itemToProcess.ItemType)
+		}
+
+		//Adding the item to the outline, its anchor and its heading, pointing at the top of the item wherever it ended up
+		if itemMovedToNextPage {
+			itemPage, itemYPosition = itemPage+1, contentsToProcessFromRecipe.PdfSettings.PageTopMargin
+		}
+		if itemToProcess.Bookmark.Text != "" {
+			lastPage := pdf.PageNo()
+			pdf.SetPage(itemPage)
+			pdf.Bookmark(itemToProcess.Bookmark.Text, itemToProcess.Bookmark.Level, itemYPosition)
+			pdf.SetPage(lastPage)
 		}
+		if itemToProcess.Anchor != "" {
+			anchors.Place(pdf, itemToProcess.Anchor, itemPage, itemYPosition)
+		}
+		if itemToProcess.Heading {
+			headings[headingIndex].Page = itemPage
+			headings[headingIndex].YPosition = itemYPosition
+			headingIndex++
+		}
+
+		if itemErr != nil {
+			itemErrors = append(itemErrors, fmt.Sprintf("%s item %d: %v", itemToProcess.ItemType, i, itemErr))
+		}
+	}
+
+	if anchorErr := anchors.CheckAllPlaced(); anchorErr != nil {
+		itemErrors = append(itemErrors, anchorErr.Error())
 	}
+	if pageLinkErr := anchors.CheckPageLinks(pdf.PageCount()); pageLinkErr != nil {
+		itemErrors = append(itemErrors, pageLinkErr.Error())
+	}
+
+	if len(itemErrors) > 0 {
+		err = errors.New(strings.Join(itemErrors, "; "))
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Building the pdf from a recipe and its data, the same pipeline is used from the command line and by the serve mode
+func RenderPDF(recipe PdfFields, data Data) (pdf *gofpdf.Fpdf, err error) {
+
+	pdf, err = InitialisePDF(recipe)
+	if err != nil {
+		return pdf, err
+	}
+
+	err = ProcessPDFContentsItems(pdf, recipe, data)
+	if err == nil && pdf.Err() {
+		err = pdf.Error()
+	}
+
+	return pdf, err
 }
 
 //////////////////////////////////////////////////////////////////////
 //Processing table
-func ProcessTablePDFItem(pdf *gofpdf.Fpdf, tableItem PdfContentItem, pdfFields PdfFields, data Data) (err error) {
+func ProcessTablePDFItem(pdf *gofpdf.Fpdf, tableItem PdfContentItem, pdfFields PdfFields, data Data, anchors *PdfAnchors) (err error) {
 
-	font := FetchTextFormattingFromRecipe(tableItem.Font)
-	tableWidth := 100.0
-	if tableItem.Width >= 0.0 {
-		tableWidth = tableItem.Width
+	font := tableItem.Font
+	tableWidth := tableItem.Width
+	columns := TableColumnsForItem(tableItem)
+	columnWidths := TableColumnWidths(columns, tableWidth)
+	if err = ValidateTableColumnStyles(columns); err != nil {
+		return err
+	}
+	if err = ValidateTableTotals(columns, tableItem.TableSettings); err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if err = ValidateValueFormat(column.Format); err != nil {
+			return fmt.Errorf("column %q: %v", column.Key, err)
+		}
+		if err = ValidateSparklineColumn(column); err != nil {
+			return err
+		}
 	}
-	columnWidth := tableWidth / 2.0
 
 	//Settings the x and y position for the text, and making position 0 equivalent to the margin that we've set
 	getXPosition := tableItem.XPosition + pdfFields.PdfSettings.PageLeftAndRightMargins
@@ -212,42 +452,114 @@ func ProcessTablePDFItem(pdf *gofpdf.Fpdf, tableItem PdfContentItem, pdfFields P
 			pdf.SetFillColor(font.HeaderFont.CellFill.Colour.R, font.HeaderFont.CellFill.Colour.G, font.HeaderFont.CellFill.Colour.B)
 			pdf.SetDrawColor(font.HeaderFont.CellBorders.Colour.R, font.HeaderFont.CellBorders.Colour.G, font.HeaderFont.CellBorders.Colour.B)
 
-			//Header row - Category cell
-			pdf.SetXY(getXPosition, getYPosition)
-			pdf.MultiCell(columnWidth, font.HeaderFont.Size+font.HeaderFont.LineSpacing, tableItem.DataSeriesCategory, font.HeaderFont.CellBorders.Style, font.HeaderFont.Alignment, font.HeaderFont.CellFill.Filled)
-			//Header row - volume cell - Here we are starting from the end of the category cell
-			pdf.SetXY(getXPosition+columnWidth, getYPosition)
-			pdf.MultiCell(columnWidth, font.HeaderFont.Size+font.HeaderFont.LineSpacing, tableItem.DataSeries, font.HeaderFont.CellBorders.Style, font.HeaderFont.Alignment, font.HeaderFont.CellFill.Filled)
+			//Header row - each cell starts from the end of the one before it
+			cellXPosition := getXPosition
+			for i, column := range columns {
+				headerText := column.Header
+				if headerText == "" {
+					headerText = column.Key
+				}
+				filled := FillCellWithAlpha(pdf, font.HeaderFont.CellFill.Colour, font.HeaderFont.CellFill.Filled, cellXPosition, getYPosition, columnWidths[i], font.HeaderFont.Size+font.HeaderFont.LineSpacing)
+				pdf.SetXY(cellXPosition, getYPosition)
+				pdf.MultiCell(columnWidths[i], font.HeaderFont.Size+font.HeaderFont.LineSpacing, headerText, font.HeaderFont.CellBorders.Style, font.HeaderFont.Alignment, filled)
+				cellXPosition = cellXPosition + columnWidths[i]
+			}
 
 			getYPosition = getYPosition + font.HeaderFont.Size + font.HeaderFont.LineSpacing
 
 			//Row formatting
-			pdf.SetFont(font.Family, font.Style, font.Size)
-			pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
-			pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
-			pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+			columnNumbers := TableColumnNumbers(columns, dataset.DataPoints)
 
 			//If the table is getting larger than the height that we've set in the recipe, break the loop and insert a row with an elipsis
 			cumulativeTableHeight := font.HeaderFont.Size + font.HeaderFont.LineSpacing
 
-			for _, point := range dataset.DataPoints {
+			for _, tableRow := range TableRowsForItem(columns, tableItem.TableSettings, dataset.DataPoints) {
 
-				//For each point from the dataset, draw the category and volume cells (rows)
+				//Group headers are written in the group header font, subtotals and the total in the footer font
+				rowHeight := font.Size + font.LineSpacing
+				switch tableRow.Kind {
+				case groupHeaderTableRow:
+					rowHeight = font.GroupHeaderFont.Size + font.GroupHeaderFont.LineSpacing
+				case subtotalTableRow, totalTableRow:
+					rowHeight = font.FooterFont.Size + font.FooterFont.LineSpacing
+				}
+
+				//For each point from the dataset, draw a cell for each column (rows)
 				pdf.SetXY(getXPosition, getYPosition)
 
 				//If the table starts getting larger than the height set for it, stop adding rows
-				if cumulativeTableHeight+0.5*(font.Size+font.LineSpacing) > tableItem.Height {
-					pdf.MultiCell(tableWidth, 0.5*(font.Size+font.LineSpacing), "...", font.CellBorders.Style, "CB", font.CellFill.Filled)
+				if cumulativeTableHeight+0.5*rowHeight > tableItem.Height {
+					pdf.SetFont(font.Family, font.Style, font.Size)
+					pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+					pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+					pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+					filled := FillCellWithAlpha(pdf, font.CellFill.Colour, font.CellFill.Filled, getXPosition, getYPosition, tableWidth, 0.5*(font.Size+font.LineSpacing))
+					pdf.MultiCell(tableWidth, 0.5*(font.Size+font.LineSpacing), "...", font.CellBorders.Style, "CB", filled)
 					break
 				}
 
-				//Point is an interface that can hold anything - any datatype. We must assert the type first, as an MSI, then we can index it, finally convert it to a string
-				pdf.MultiCell(columnWidth, font.Size+font.LineSpacing, point.(map[string]interface{})[tableItem.DataSeriesCategory].(string), font.CellBorders.Style, font.Alignment, font.CellFill.Filled)
-				pdf.SetXY(getXPosition+columnWidth, getYPosition)
-				pdf.MultiCell(columnWidth, font.Size+font.LineSpacing, fmt.Sprintf("%.f", point.(map[string]interface{})[tableItem.DataSeries]), font.CellBorders.Style, font.Alignment, font.CellFill.Filled)
+				cellXPosition = getXPosition
+				switch tableRow.Kind {
+
+				case groupHeaderTableRow:
+
+					//The group's value across the whole width of the table
+					setTableRowFont(pdf, font.GroupHeaderFont)
+					filled := FillCellWithAlpha(pdf, font.GroupHeaderFont.CellFill.Colour, font.GroupHeaderFont.CellFill.Filled, getXPosition, getYPosition, tableWidth, rowHeight)
+					pdf.MultiCell(tableWidth, rowHeight, tableRow.Label, font.GroupHeaderFont.CellBorders.Style, font.GroupHeaderFont.Alignment, filled)
+
+				case subtotalTableRow, totalTableRow:
+
+					setTableRowFont(pdf, font.FooterFont)
+					for i := range columns {
+						filled := FillCellWithAlpha(pdf, font.FooterFont.CellFill.Colour, font.FooterFont.CellFill.Filled, cellXPosition, getYPosition, columnWidths[i], rowHeight)
+						pdf.SetXY(cellXPosition, getYPosition)
+						pdf.MultiCell(columnWidths[i], rowHeight, TextForFont(pdf, font.FooterFont.Family, TotalRowCellText(tableRow, columns, i)), font.FooterFont.CellBorders.Style, font.FooterFont.Alignment, filled)
+						cellXPosition = cellXPosition + columnWidths[i]
+					}
+
+				default:
+
+					row := tableRow.Values
+					pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+					for i, column := range columns {
+
+						//A column can take each row's link from another field in the data, linked cells are written in the link colour
+						linkTarget, _ := row[column.LinkKey].(string)
+						linked := column.LinkKey != "" && linkTarget != ""
+
+						//Each cell can look different depending on its row and value
+						cellStyle := CellStyleForTable(font, tableItem.TableSettings, column, columnNumbers[i], tableRow.DataIndex, row[column.Key], linked)
+						pdf.SetFont(font.Family, cellStyle.FontStyle, font.Size)
+						pdf.SetTextColor(cellStyle.TextColour.R, cellStyle.TextColour.G, cellStyle.TextColour.B)
+						filled := FillCellWithAlpha(pdf, cellStyle.FillColour, cellStyle.Filled, cellXPosition, getYPosition, columnWidths[i], rowHeight)
+						pdf.SetFillColor(cellStyle.FillColour.R, cellStyle.FillColour.G, cellStyle.FillColour.B)
+
+						pdf.SetXY(cellXPosition, getYPosition)
+						if column.Type == "sparkline" {
+							//The cell is drawn empty for its borders and fill, with the sparkline on top of it
+							sparklineValues, err := SparklineValuesForRow(column, row, data)
+							if err != nil {
+								return err
+							}
+							pdf.MultiCell(columnWidths[i], rowHeight, "", font.CellBorders.Style, font.Alignment, filled)
+							DrawSparkline(pdf, column.Sparkline, sparklineValues, cellXPosition, getYPosition, columnWidths[i], rowHeight)
+						} else {
+							pdf.MultiCell(columnWidths[i], rowHeight, TextForFont(pdf, font.Family, FormatValue(row[column.Key], column.Format)), font.CellBorders.Style, font.Alignment, filled)
+						}
+
+						if linked {
+							if err = AddLinkArea(pdf, anchors, cellXPosition, getYPosition, columnWidths[i], rowHeight, linkTarget); err != nil {
+								return err
+							}
+						}
+
+						cellXPosition = cellXPosition + columnWidths[i]
+					}
+				}
 
-				getYPosition = getYPosition + font.Size + font.LineSpacing
-				cumulativeTableHeight = cumulativeTableHeight + font.Size + font.LineSpacing
+				getYPosition = getYPosition + rowHeight
+				cumulativeTableHeight = cumulativeTableHeight + rowHeight
 			}
 		}
 	}
@@ -256,130 +568,164 @@ func ProcessTablePDFItem(pdf *gofpdf.Fpdf, tableItem PdfContentItem, pdfFields P
 }
 
 //////////////////////////////////////////////////////////////////////
-//Processing vertical bar charts
-func ProcessVerticalBarChartPDFItem(pdf *gofpdf.Fpdf, vbarItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+//The table's columns from the recipe, or the category and series columns when it doesn't list any
+func TableColumnsForItem(tableItem PdfContentItem) (columns []TableColumn) {
+	if len(tableItem.Columns) > 0 {
+		return tableItem.Columns
+	}
+	return []TableColumn{{Key: tableItem.DataSeriesCategory}, {Key: tableItem.DataSeries}}
+}
+
+//Columns with a width keep it, the rest share what's left of the table's width equally
+func TableColumnWidths(columns []TableColumn, tableWidth float64) (columnWidths []float64) {
+
+	remainingWidth := tableWidth
+	columnsWithoutWidth := 0.0
+	for _, column := range columns {
+		if column.Width > 0.0 {
+			remainingWidth = remainingWidth - column.Width
+		} else {
+			columnsWithoutWidth++
+		}
+	}
 
-	yAxisTicks := 5.0
-	if vbarItem.ChartSettings.NumberOfYAxisTicks > 0 {
-		yAxisTicks = vbarItem.ChartSettings.NumberOfYAxisTicks
+	for _, column := range columns {
+		if column.Width > 0.0 {
+			columnWidths = append(columnWidths, column.Width)
+		} else {
+			columnWidths = append(columnWidths, math.Max(remainingWidth, 0.0)/columnsWithoutWidth)
+		}
 	}
-	//We want to count the x position and max y position as ticks marks so we take one less
-	yAxisTicks = yAxisTicks - 1
 
-	for _, dataset := range data {
-		if vbarItem.DataSource == dataset.DataSource {
+	return columnWidths
+}
 
-			font := FetchTextFormattingFromRecipe(vbarItem.ChartSettings.ChartTextFont)
+//Turning a value from the data into text without a format, numbers are rounded to whole numbers
+func FormatCellValue(value interface{}) string {
+	return FormatValue(value, ValueFormat{})
+}
 
-			chartBoxX := vbarItem.XPosition + pdfSettings.PdfSettings.PageLeftAndRightMargins
-			chartBoxY := vbarItem.YPosition + pdfSettings.PdfSettings.PageTopMargin
-			chartWidth := vbarItem.Width - vbarItem.ChartSettings.DistanceFromSidesOfChartArea
-			yAxisXPosition := chartBoxX + vbarItem.ChartSettings.DistanceFromSidesOfChartArea
-			yAxisMaxYPosition := chartBoxY + vbarItem.ChartSettings.DistanceFromTopOfChartArea
-			axisZeroPosition := chartBoxY + vbarItem.Height - vbarItem.ChartSettings.DistanceFromBottomOfChartArea
-			chartHeight := axisZeroPosition - yAxisMaxYPosition
+//////////////////////////////////////////////////////////////////////
+//Processing vertical bar charts
+func ProcessVerticalBarChartPDFItem(pdf *gofpdf.Fpdf, vbarItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
 
-			//First we draw the charts background box container, from the chartsettings in the markup
-			pdf.SetFillColor(vbarItem.ChartSettings.WatermarkFormat.FillColour.R, vbarItem.ChartSettings.WatermarkFormat.FillColour.G, vbarItem.ChartSettings.WatermarkFormat.FillColour.B)
-			pdf.SetDrawColor(vbarItem.ChartSettings.WatermarkFormat.BorderColour.R, vbarItem.ChartSettings.WatermarkFormat.BorderColour.G, vbarItem.ChartSettings.WatermarkFormat.BorderColour.B)
+	if err = ValidateCategoryChart(vbarItem); err != nil {
+		return err
+	}
+	if err = ValidateDataLabels(vbarItem.ChartSettings.DataLabels); err != nil {
+		return err
+	}
 
-			pdf.Rect(chartBoxX, chartBoxY, vbarItem.Width, vbarItem.Height, vbarItem.ChartSettings.WatermarkFormat.Style)
+	for _, dataset := range data {
+		if vbarItem.DataSource == dataset.DataSource {
 
 			//Getting the highest value in the dataset in order to scale the bars and set the max value on the y-axis
+			////The categories and values are kept for the annotations
 			maxValueFromData := 0.0
-			numberCategories := 0.0
+			var categoryValues []interface{}
+			var categories []string
+			var seriesValues []float64
 			for _, valuesFromDataPoints := range dataset.DataPoints {
 				if valuesFromDataPoints.(map[string]interface{})[vbarItem.DataSeries].(float64) > maxValueFromData {
 					maxValueFromData = valuesFromDataPoints.(map[string]interface{})[vbarItem.DataSeries].(float64)
 				}
-				numberCategories = numberCategories + 1
+				categoryValues = append(categoryValues, valuesFromDataPoints.(map[string]interface{})[vbarItem.DataSeriesCategory])
+				categories = append(categories, FormatCellValue(valuesFromDataPoints.(map[string]interface{})[vbarItem.DataSeriesCategory]))
+				seriesValues = append(seriesValues, valuesFromDataPoints.(map[string]interface{})[vbarItem.DataSeries].(float64))
 
 			}
+			if err = ValidateChartAnnotations(vbarItem.ChartSettings.Annotations, categories); err != nil {
+				return err
+			}
 
-			maxValueForYAxis := GetMaxValueForAxisOnChart(maxValueFromData)
-
-			yAxisTickLabel := maxValueForYAxis
-
-			//Drawing the y axis
-			pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
-			pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-			pdf.Line(yAxisXPosition, yAxisMaxYPosition, yAxisXPosition, axisZeroPosition)
-			tickIntervalOnAxis := chartHeight / yAxisTicks
-			tickYPosition := yAxisMaxYPosition
-
-			pdf.SetFont(font.Family, font.Style, font.Size)
-			pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
-			tickLength := vbarItem.ChartSettings.TickMarkLength
-			for i := 0.0; i <= yAxisTicks; {
-
-				//Drawing the tick line
-				pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
-				pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-				pdf.Line(yAxisXPosition, tickYPosition, yAxisXPosition-tickLength, tickYPosition)
-				//Calculating the position of the tick labels
-				////We work out the gap between the yaxis and chart box, set label to yaxis, but justify the position of the label text right
-				pdf.SetXY(chartBoxX, tickYPosition-(0.5*font.Size))
-				pdf.CellFormat(vbarItem.ChartSettings.DistanceFromSidesOfChartArea-vbarItem.ChartSettings.TickMarkLength, font.Size, fmt.Sprintf("%.f", yAxisTickLabel), "", 0, "RM", false, 0, "")
-
-				tickYPosition = tickYPosition + tickIntervalOnAxis
-				yAxisTickLabel = yAxisTickLabel - (maxValueForYAxis / yAxisTicks)
-				i++
+			minValueFromData, _ := valueRange(seriesValues)
+			valueScale, err := CategoryValueScale(vbarItem.ChartSettings.YAxisType, vbarItem.ChartSettings.NumberOfYAxisTicks, minValueFromData, maxValueFromData)
+			if err != nil {
+				return err
+			}
+			plotArea, err := CategoryPlotArea(vbarItem, pdfSettings, categoryValues, valueScale)
+			if err != nil {
+				return err
 			}
 
-			//Drawing x axis tick marks
-			tickXInterval := (chartWidth - vbarItem.ChartSettings.DistanceFromSidesOfChartArea) / (numberCategories)
-			tickXPosition := yAxisXPosition
+			//First we draw the charts background box container, from the chartsettings in the markup
+			DrawChartBackground(pdf, vbarItem.ChartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, vbarItem.Width, vbarItem.Height)
+
+			//Drawing the y axis with its ticks and gridlines
+			DrawCategoryChartValueAxis(pdf, vbarItem.ChartSettings, plotArea)
 
-			//Bars and x axis labels
-			for _, values := range dataset.DataPoints {
+			//Shading the annotation bands, behind the bars
+			DrawChartBands(pdf, vbarItem.ChartSettings.Annotations, plotArea)
 
-				//Drawing the tick line
-				pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
-				pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-				pdf.Line(tickXPosition, axisZeroPosition, tickXPosition, axisZeroPosition+tickLength)
-				//Calculating the position of the tick labels
-				pdf.SetXY(tickXPosition, axisZeroPosition+(0.5*tickLength))
-				pdf.CellFormat(tickXInterval, font.Size, values.(map[string]interface{})[vbarItem.DataSeriesCategory].(string), "", 0, "CM", false, 0, "")
+			//The bars' borders are drawn in the axis line width
+			pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
+			for categoryIndex, value := range seriesValues {
+				barXPosition := plotArea.CategoryCentre(categoryIndex) - 0.5*plotArea.CategoryWidth + vbarItem.ChartSettings.GapBetweenBars
+				barWidth := plotArea.CategoryWidth - (2 * vbarItem.ChartSettings.GapBetweenBars)
 
 				//Drawing the bars
-				////Bar height relative to the max value on the y axis
-				barHeight := (values.(map[string]interface{})[vbarItem.DataSeries].(float64) / maxValueForYAxis) * (axisZeroPosition - yAxisMaxYPosition)
+				////Bar height relative to the scale on the y axis
+				barHeight := plotArea.Bottom - plotArea.YPosition(value)
 				////Bar formatting
 				pdf.SetFillColor(vbarItem.ChartSettings.SeriesFormat.FillColour.R, vbarItem.ChartSettings.SeriesFormat.FillColour.G, vbarItem.ChartSettings.SeriesFormat.FillColour.B)
 				pdf.SetDrawColor(vbarItem.ChartSettings.SeriesFormat.BorderColour.R, vbarItem.ChartSettings.SeriesFormat.BorderColour.G, vbarItem.ChartSettings.SeriesFormat.BorderColour.B)
 				////Drawing the bars
-				pdf.Rect(tickXPosition+vbarItem.ChartSettings.GapBetweenBars, axisZeroPosition, tickXInterval-(2*vbarItem.ChartSettings.GapBetweenBars), -barHeight, vbarItem.ChartSettings.SeriesFormat.Style)
+				DrawWithAlpha(pdf, vbarItem.ChartSettings.SeriesFormat.FillColour, func() {
+					pdf.Rect(barXPosition, plotArea.Bottom, barWidth, -barHeight, vbarItem.ChartSettings.SeriesFormat.Style)
+				})
 
-				tickXPosition = tickXPosition + tickXInterval
+				//Writing the bar's value on it
+				if vbarItem.ChartSettings.DataLabels.Show {
+					DrawBarDataLabel(pdf, vbarItem.ChartSettings.DataLabels, value, barXPosition, barWidth, plotArea.Bottom, barHeight, plotArea.Top)
+				}
 			}
-			//Drawing final tickmark on x axis
-			pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
-			pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-			pdf.Line(tickXPosition, axisZeroPosition, tickXPosition, axisZeroPosition+tickLength)
 
-			//Drawing the x axis line
-			pdf.SetLineWidth(vbarItem.ChartSettings.AxisFormat.LineWidth)
-			pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-			pdf.Line(yAxisXPosition, axisZeroPosition, chartBoxX+chartWidth, axisZeroPosition)
+			//Drawing the x axis with its ticks and category labels
+			DrawCategoryChartCategoryAxis(pdf, vbarItem.ChartSettings, plotArea, categoryValues)
+
+			//Drawing the reference lines and callouts on top of the bars
+			DrawChartAnnotations(pdf, vbarItem.ChartSettings.Annotations, plotArea, categories, seriesValues)
 
 			//Adding the chart title
-			////Font formatting
-			pdf.SetFont(vbarItem.ChartSettings.ChartTitle.Font.Family, vbarItem.ChartSettings.ChartTitle.Font.Style, vbarItem.ChartSettings.ChartTitle.Font.Size)
-			pdf.SetFillColor(vbarItem.ChartSettings.ChartTitle.Font.CellFill.Colour.R, vbarItem.ChartSettings.ChartTitle.Font.CellFill.Colour.G, vbarItem.ChartSettings.ChartTitle.Font.CellFill.Colour.B)
-			pdf.SetTextColor(vbarItem.ChartSettings.ChartTitle.Font.Colour.R, vbarItem.ChartSettings.ChartTitle.Font.Colour.G, vbarItem.ChartSettings.ChartTitle.Font.Colour.B)
-			pdf.SetDrawColor(vbarItem.ChartSettings.AxisFormat.LineColour.R, vbarItem.ChartSettings.AxisFormat.LineColour.G, vbarItem.ChartSettings.AxisFormat.LineColour.B)
-			////Getting the title width in order to set it's centre alignment and the width of the cell
-			titleWidth := pdf.GetStringWidth(vbarItem.ChartSettings.ChartTitle.Text)
-			////Setting the position of the title
-			pdf.SetXY(((chartBoxX + (vbarItem.Width / 2)) - (0.5 * titleWidth)), chartBoxY+vbarItem.ChartSettings.ChartTitle.DistanceFromTopOfChartArea)
-			////Adding the text
-			pdf.CellFormat(titleWidth+5, vbarItem.ChartSettings.ChartTitle.Font.Size+vbarItem.ChartSettings.ChartTitle.Font.LineSpacing, vbarItem.ChartSettings.ChartTitle.Text, vbarItem.ChartSettings.ChartTitle.Font.CellBorders.Style, 0, vbarItem.ChartSettings.ChartTitle.Font.Alignment, vbarItem.ChartSettings.ChartTitle.Font.CellFill.Filled, 0, "")
+			DrawChartTitle(pdf, vbarItem.ChartSettings, plotArea.ChartBoxX, plotArea.ChartBoxY, vbarItem.Width)
+
+			//Adding the axis titles
+			DrawXAxisTitle(pdf, vbarItem.ChartSettings.XAxisTitle, plotArea.ChartBoxX, vbarItem.Width, plotArea.ChartBoxY+vbarItem.Height)
+			DrawYAxisTitle(pdf, vbarItem.ChartSettings.YAxisTitle, plotArea.ChartBoxX, plotArea.Top, plotArea.Bottom)
 
 		}
 	}
 	return err
 }
 
+//////////////////////////////////////////////////////////////////////
+//Drawing a chart's background box, from the watermark format in its chart settings
+func DrawChartBackground(pdf *gofpdf.Fpdf, chartSettings ChartSettings, chartBoxX, chartBoxY, chartBoxWidth, chartBoxHeight float64) {
+
+	pdf.SetFillColor(chartSettings.WatermarkFormat.FillColour.R, chartSettings.WatermarkFormat.FillColour.G, chartSettings.WatermarkFormat.FillColour.B)
+	pdf.SetDrawColor(chartSettings.WatermarkFormat.BorderColour.R, chartSettings.WatermarkFormat.BorderColour.G, chartSettings.WatermarkFormat.BorderColour.B)
+
+	DrawWithAlpha(pdf, chartSettings.WatermarkFormat.FillColour, func() {
+		pdf.Rect(chartBoxX, chartBoxY, chartBoxWidth, chartBoxHeight, chartSettings.WatermarkFormat.Style)
+	})
+}
+
+//Writing a chart's title centred across the top of its chart box
+func DrawChartTitle(pdf *gofpdf.Fpdf, chartSettings ChartSettings, chartBoxX, chartBoxY, chartBoxWidth float64) {
+
+	////Font formatting
+	pdf.SetFont(chartSettings.ChartTitle.Font.Family, chartSettings.ChartTitle.Font.Style, chartSettings.ChartTitle.Font.Size)
+	pdf.SetFillColor(chartSettings.ChartTitle.Font.CellFill.Colour.R, chartSettings.ChartTitle.Font.CellFill.Colour.G, chartSettings.ChartTitle.Font.CellFill.Colour.B)
+	pdf.SetTextColor(chartSettings.ChartTitle.Font.Colour.R, chartSettings.ChartTitle.Font.Colour.G, chartSettings.ChartTitle.Font.Colour.B)
+	pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+	////Getting the title width in order to set it's centre alignment and the width of the cell
+	titleWidth := pdf.GetStringWidth(chartSettings.ChartTitle.Text)
+	////Setting the position of the title
+	pdf.SetXY(((chartBoxX + (chartBoxWidth / 2)) - (0.5 * titleWidth)), chartBoxY+chartSettings.ChartTitle.DistanceFromTopOfChartArea)
+	////Adding the text
+	pdf.CellFormat(titleWidth+5, chartSettings.ChartTitle.Font.Size+chartSettings.ChartTitle.Font.LineSpacing, chartSettings.ChartTitle.Text, chartSettings.ChartTitle.Font.CellBorders.Style, 0, chartSettings.ChartTitle.Font.Alignment, chartSettings.ChartTitle.Font.CellFill.Filled, 0, "")
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 //Working out what the max value should be on the y axis based on rounding the max value from the dataset
 func GetMaxValueForAxisOnChart(maxValueFromData float64) (roundedValue float64) {
@@ -392,9 +738,9 @@ func GetMaxValueForAxisOnChart(maxValueFromData float64) (roundedValue float64)
 
 //////////////////////////////////////////////////////////////////////
 //Processing text blocks
-func ProcessTextBlockPDFItem(pdf *gofpdf.Fpdf, textBlockItem PdfContentItem, pdfSettings PdfFields) (err error) {
+func ProcessTextBlockPDFItem(pdf *gofpdf.Fpdf, textBlockItem PdfContentItem, pdfSettings PdfFields, anchors *PdfAnchors) (err error) {
 
-	font := FetchTextFormattingFromRecipe(textBlockItem.Font)
+	font := textBlockItem.Font
 
 	pdf.SetFont(font.Family, font.Style, font.Size)
 	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
@@ -402,231 +748,131 @@ func ProcessTextBlockPDFItem(pdf *gofpdf.Fpdf, textBlockItem PdfContentItem, pdf
 	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
 
 	//Settings the x and y position for the text, and making position 0 equivalent to the margin that we've set
-	pdf.SetXY(textBlockItem.XPosition+pdfSettings.PdfSettings.PageLeftAndRightMargins, textBlockItem.YPosition+pdfSettings.PdfSettings.PageTopMargin)
+	textXPosition := textBlockItem.XPosition + pdfSettings.PdfSettings.PageLeftAndRightMargins
+	textYPosition := textBlockItem.YPosition + pdfSettings.PdfSettings.PageTopMargin
+
+	//Text with markup or links in it is laid out word by word so that the styles and links can sit inside the lines. Text with an
+	//overflow policy is laid out the same way so that it can be measured against the height, see FitRichTextToBox. So is text with a
+	//see-through fill, which has to be drawn under the text on its own and so needs the block's height first
+	seeThroughFill := font.CellFill.Filled && font.CellFill.Colour.A > 0.0 && font.CellFill.Colour.A < 1.0
+	if textBlockItem.Markup != "" || textBlockItem.Overflow != "" || seeThroughFill || linkSpanPattern.MatchString(textBlockItem.Text) {
+		runs, parseErr := ParseRichText(textBlockItem.Text, textBlockItem.Markup)
+		if parseErr != nil {
+			return parseErr
+		}
+		fittedFont, lines, clip, fitErr := FitRichTextToBox(pdf, font, runs, textBlockItem.Width, textBlockItem.Height, textBlockItem.Overflow)
+		if fitErr != nil {
+			return fitErr
+		}
+		if clip {
+			pdf.ClipRect(textXPosition, textYPosition, textBlockItem.Width, textBlockItem.Height, false)
+			defer pdf.ClipEnd()
+		}
+		return DrawRichText(pdf, anchors, fittedFont, lines, textXPosition, textYPosition, textBlockItem.Width)
+	}
 
+	pdf.SetXY(textXPosition, textYPosition)
 	pdf.MultiCell(textBlockItem.Width, font.Size+font.LineSpacing, textBlockItem.Text, font.CellBorders.Style, font.Alignment, font.CellFill.Filled)
 
 	return err
 }
 
-//////////////////////////////////////////////////////////////////////////////////////////////////
-//Setting defaults font settings and where there is a font element set in the recipe, use that
-func FetchTextFormattingFromRecipe(formattingFromRecipeForItem Font) (font Font) {
-	//Setting some default font values
-	font.Size = 9.0
-	font.Style = ""
-	font.Family = "Helvetica"
-	font.Alignment = "CM"
-	font.LineSpacing = 3.0
-	font.Colour.R = 0
-	font.Colour.G = 0
-	font.Colour.B = 0
-	font.CellFill.Filled = false
-	font.CellFill.Colour.R = 255
-	font.CellFill.Colour.G = 255
-	font.CellFill.Colour.B = 255
-	font.CellBorders.Style = "1"
-	font.CellBorders.Colour.R = 0
-	font.CellBorders.Colour.G = 0
-	font.CellBorders.Colour.B = 0
-	font.HeaderFont.Size = 10.0
-	font.HeaderFont.Style = "B"
-	font.HeaderFont.Family = "Helvetica"
-	font.HeaderFont.Alignment = "CM"
-	font.HeaderFont.LineSpacing = 3.0
-	font.HeaderFont.Colour.R = 0
-	font.HeaderFont.Colour.G = 0
-	font.HeaderFont.Colour.B = 0
-	font.HeaderFont.CellFill.Filled = false
-	font.HeaderFont.CellFill.Colour.R = 213
-	font.HeaderFont.CellFill.Colour.G = 213
-	font.HeaderFont.CellFill.Colour.B = 213
-	font.HeaderFont.CellBorders.Style = "0"
-	font.HeaderFont.CellBorders.Colour.R = 0
-	font.HeaderFont.CellBorders.Colour.G = 0
-	font.HeaderFont.CellBorders.Colour.B = 0
-
-	if formattingFromRecipeForItem.Size > 0.0 {
-		font.Size = formattingFromRecipeForItem.Size
-	}
-	if formattingFromRecipeForItem.Style == "B" || formattingFromRecipeForItem.Style == "" || formattingFromRecipeForItem.Style == "I" || formattingFromRecipeForItem.Style == "BI" || formattingFromRecipeForItem.Style == "IB" {
-		font.Style = formattingFromRecipeForItem.Style
-	}
-
-	if len(formattingFromRecipeForItem.Family) > 0 {
-		font.Family = formattingFromRecipeForItem.Family
-	}
-
-	if formattingFromRecipeForItem.Colour.R >= 0 {
-		font.Colour.R = formattingFromRecipeForItem.Colour.R
-	}
-	if formattingFromRecipeForItem.Colour.G >= 0 {
-		font.Colour.G = formattingFromRecipeForItem.Colour.G
-	}
-	if formattingFromRecipeForItem.Colour.B >= 0 {
-		font.Colour.B = formattingFromRecipeForItem.Colour.B
-	}
-
-	if len(formattingFromRecipeForItem.CellBorders.Style) > 0 {
-		font.CellBorders.Style = formattingFromRecipeForItem.CellBorders.Style
-	}
-
-	if formattingFromRecipeForItem.CellBorders.Colour.R >= 0 {
-		font.CellBorders.Colour.R = formattingFromRecipeForItem.CellBorders.Colour.R
-	}
-	if formattingFromRecipeForItem.CellBorders.Colour.G >= 0 {
-		font.CellBorders.Colour.G = formattingFromRecipeForItem.CellBorders.Colour.G
-	}
-	if formattingFromRecipeForItem.CellBorders.Colour.B >= 0 {
-		font.CellBorders.Colour.B = formattingFromRecipeForItem.CellBorders.Colour.B
-	}
-
-	if formattingFromRecipeForItem.CellFill.Filled == true {
-		font.CellFill.Filled = formattingFromRecipeForItem.CellFill.Filled
-	}
-
-	if formattingFromRecipeForItem.CellFill.Colour.R >= 0 {
-		font.CellFill.Colour.R = formattingFromRecipeForItem.CellFill.Colour.R
-	}
-	if formattingFromRecipeForItem.CellFill.Colour.G >= 0 {
-		font.CellFill.Colour.G = formattingFromRecipeForItem.CellFill.Colour.G
-	}
-	if formattingFromRecipeForItem.CellFill.Colour.B >= 0 {
-		font.CellFill.Colour.B = formattingFromRecipeForItem.CellFill.Colour.B
-	}
+//////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+//Initialising the pdf page from the recipe's settings. Defaults for anything not in the recipe are already filled in by LoadRecipe
+func InitialisePDF(recipeFile PdfFields) (pdf *gofpdf.Fpdf, err error) {
 
-	if formattingFromRecipeForItem.LineSpacing >= 0.0 {
-		font.LineSpacing = formattingFromRecipeForItem.LineSpacing
-	}
+	settings := recipeFile.PdfSettings
 
-	if formattingFromRecipeForItem.HeaderFont.Size > 0.0 {
-		font.HeaderFont.Size = formattingFromRecipeForItem.HeaderFont.Size
-	}
-	if formattingFromRecipeForItem.HeaderFont.Style == "B" || formattingFromRecipeForItem.HeaderFont.Style == "" || formattingFromRecipeForItem.HeaderFont.Style == "I" || formattingFromRecipeForItem.HeaderFont.Style == "BI" || formattingFromRecipeForItem.HeaderFont.Style == "IB" {
-		font.HeaderFont.Style = formattingFromRecipeForItem.HeaderFont.Style
+	//Checking the recipe's pdf settings are valid. Every problem is reported together, like the items' errors
+	var settingsErrors []string
+	if settings.PageOrientation != "L" && settings.PageOrientation != "P" {
+		settingsErrors = append(settingsErrors, fmt.Sprintf("unsupported page orientation %q, expected L or P", settings.PageOrientation))
 	}
-
-	if len(formattingFromRecipeForItem.HeaderFont.Family) > 0 {
-		font.HeaderFont.Family = formattingFromRecipeForItem.HeaderFont.Family
+	if settings.PageUnits != "pt" && settings.PageUnits != "mm" && settings.PageUnits != "cm" && settings.PageUnits != "in" {
+		settingsErrors = append(settingsErrors, fmt.Sprintf("unsupported page units %q, expected one of pt, mm, cm or in", settings.PageUnits))
 	}
 
-	if formattingFromRecipeForItem.HeaderFont.Colour.R >= 0 {
-		font.HeaderFont.Colour.R = formattingFromRecipeForItem.HeaderFont.Colour.R
+	//A page is A4 unless it has a named size or both a custom width and height, having both of those would leave one of them ignored
+	pageSize := settings.PageSize
+	customPageSize := settings.PageWidth > 0.0 && settings.PageHeight > 0.0
+	switch {
+	case customPageSize && pageSize != "":
+		settingsErrors = append(settingsErrors, fmt.Sprintf("page size %q and a custom pageWidth and pageHeight are both set, only one of them can be used", pageSize))
+	case !customPageSize && (settings.PageWidth > 0.0 || settings.PageHeight > 0.0):
+		settingsErrors = append(settingsErrors, fmt.Sprintf("a custom page size needs both pageWidth and pageHeight, got %v by %v", settings.PageWidth, settings.PageHeight))
 	}
-	if formattingFromRecipeForItem.HeaderFont.Colour.G >= 0 {
-		font.HeaderFont.Colour.G = formattingFromRecipeForItem.HeaderFont.Colour.G
+	if pageSize == "" && !customPageSize {
+		pageSize = "A4"
 	}
-	if formattingFromRecipeForItem.HeaderFont.Colour.B >= 0 {
-		font.HeaderFont.Colour.B = formattingFromRecipeForItem.HeaderFont.Colour.B
+	switch strings.ToLower(pageSize) {
+	case "a3", "a4", "a5", "letter", "legal", "tabloid":
+	default:
+		if !customPageSize {
+			settingsErrors = append(settingsErrors, fmt.Sprintf("unsupported page size %q, expected one of A3, A4, A5, Letter, Legal or Tabloid", pageSize))
+		}
 	}
 
-	if len(formattingFromRecipeForItem.HeaderFont.CellBorders.Style) > 0 {
-		font.HeaderFont.CellBorders.Style = formattingFromRecipeForItem.HeaderFont.CellBorders.Style
+	//The right margin matches the left one unless it's set separately
+	rightMarginPage := settings.PageLeftAndRightMargins
+	if settings.PageRightMargin != nil {
+		rightMarginPage = *settings.PageRightMargin
 	}
 
-	if formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.R >= 0 {
-		font.HeaderFont.CellBorders.Colour.R = formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.R
-	}
-	if formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.G >= 0 {
-		font.HeaderFont.CellBorders.Colour.G = formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.G
+	//Page dimensions are always given in portrait, gofpdf swaps them itself when the orientation is landscape
+	initType := gofpdf.InitType{
+		OrientationStr: settings.PageOrientation,
+		UnitStr:        settings.PageUnits,
+		SizeStr:        pageSize,
 	}
-	if formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.B >= 0 {
-		font.HeaderFont.CellBorders.Colour.B = formattingFromRecipeForItem.HeaderFont.CellBorders.Colour.B
+	//Custom page dimensions are in the page units
+	if customPageSize {
+		initType.SizeStr = ""
+		initType.Size = gofpdf.SizeType{Wd: math.Min(settings.PageWidth, settings.PageHeight), Ht: math.Max(settings.PageWidth, settings.PageHeight)}
 	}
 
-	if formattingFromRecipeForItem.HeaderFont.CellFill.Filled == true {
-		font.HeaderFont.CellFill.Filled = formattingFromRecipeForItem.HeaderFont.CellFill.Filled
-	}
+	pdf = gofpdf.NewCustom(&initType)
 
-	if formattingFromRecipeForItem.HeaderFont.CellFill.Colour.R >= 0 {
-		font.HeaderFont.CellFill.Colour.R = formattingFromRecipeForItem.HeaderFont.CellFill.Colour.R
-	}
-	if formattingFromRecipeForItem.HeaderFont.CellFill.Colour.G >= 0 {
-		font.HeaderFont.CellFill.Colour.G = formattingFromRecipeForItem.HeaderFont.CellFill.Colour.G
-	}
-	if formattingFromRecipeForItem.HeaderFont.CellFill.Colour.B >= 0 {
-		font.HeaderFont.CellFill.Colour.B = formattingFromRecipeForItem.HeaderFont.CellFill.Colour.B
+	//Registering any font files from the recipe, then checking that the fonts used by the items are all available
+	if fontErr := RegisterFontsFromRecipe(pdf, recipeFile); fontErr != nil {
+		settingsErrors = append(settingsErrors, fontErr.Error())
+	} else if fontErr := ValidateFontsInRecipe(recipeFile); fontErr != nil {
+		settingsErrors = append(settingsErrors, fontErr.Error())
 	}
 
-	if formattingFromRecipeForItem.LineSpacing >= 0.0 {
-		font.LineSpacing = formattingFromRecipeForItem.LineSpacing
+	if metadataErr := ApplyMetadata(pdf, recipeFile.Metadata); metadataErr != nil {
+		settingsErrors = append(settingsErrors, metadataErr.Error())
 	}
-
-	return font
-
-}
-
-//////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-//Initialising the standard A4 pdf page, and where there are settings for the page set in the recipe, use those
-func InitialisePDF(recipeFile PdfFields) (pdf *gofpdf.Fpdf, err error) {
-
-	//Default values incase the recipe file doesn't contain the settings
-	pageOrientation := "P"
-	pageUnits := "pt"
-
-	pageWidth := 595.28
-	pageHeight := 841.89
-
-	//Standard margin sizes
-	leftAndRightMargin := 28.3
-	topMarginPage := 42.5
-
-	//Reading the recipe for the pdf's settings and applying if present and valid
-	if recipeFile.PdfSettings.PageOrientation == "L" || recipeFile.PdfSettings.PageOrientation == "P" {
-		pageOrientation = recipeFile.PdfSettings.PageOrientation
-	}
-	if recipeFile.PdfSettings.PageUnits == "pt" || recipeFile.PdfSettings.PageUnits == "mm" || recipeFile.PdfSettings.PageUnits == "cm" || recipeFile.PdfSettings.PageUnits == "in" {
-		pageUnits = recipeFile.PdfSettings.PageUnits
-	}
-	if recipeFile.PdfSettings.PageWidth > 0.0 {
-		pageWidth = recipeFile.PdfSettings.PageWidth
-	}
-	if recipeFile.PdfSettings.PageHeight > 0.0 {
-		pageHeight = recipeFile.PdfSettings.PageHeight
+	if bookmarkErr := ValidateBookmarksInRecipe(recipeFile); bookmarkErr != nil {
+		settingsErrors = append(settingsErrors, bookmarkErr.Error())
 	}
-	if recipeFile.PdfSettings.PageLeftAndRightMargins >= 0.0 {
-		leftAndRightMargin = recipeFile.PdfSettings.PageLeftAndRightMargins
-	}
-	if recipeFile.PdfSettings.PageTopMargin >= 0.0 {
-		topMarginPage = recipeFile.PdfSettings.PageTopMargin
-	}
-
-	//If the orientation is landscape, switch the dimensions
-	if pageOrientation == "L" {
-		pageWidth = pageHeight
-		pageHeight = pageWidth
+	if len(settingsErrors) > 0 {
+		err = errors.New(strings.Join(settingsErrors, "; "))
 	}
 
-	//If a watermark is specified then draw a rectangle that's the size of the page
-	watermarkR := -1
-	watermarkG := -1
-	watermarkB := -1
+	pdf.SetMargins(settings.PageLeftAndRightMargins, settings.PageTopMargin, rightMarginPage)
+	pdf.SetAutoPageBreak(true, settings.PageBottomMargin)
 
-	if recipeFile.PdfSettings.Watermark.R >= 0 {
-		watermarkR = recipeFile.PdfSettings.Watermark.R
-	}
-	if recipeFile.PdfSettings.Watermark.G >= 0 {
-		watermarkG = recipeFile.PdfSettings.Watermark.G
-	}
-	if recipeFile.PdfSettings.Watermark.B >= 0 {
-		watermarkB = recipeFile.PdfSettings.Watermark.B
+	//If a watermark is specified then draw a rectangle that's the size of the page. It's drawn from the header so that pages
+	//added by page breaks get it too
+	if settings.Watermark != nil {
+		pdf.SetHeaderFunc(func() {
+			//Using the size of the page we actually got, so the orientation is already applied
+			watermarkWidth, watermarkHeight := pdf.GetPageSize()
+			pdf.SetFillColor(settings.Watermark.R, settings.Watermark.G, settings.Watermark.B)
+			DrawWithAlpha(pdf, *settings.Watermark, func() {
+				pdf.Rect(0, 0, watermarkWidth, watermarkHeight, "F")
+			})
+		})
 	}
 
-	pdf = gofpdf.New(pageOrientation, pageUnits, "A4", "")
 	pdf.AddPage()
-	pdf.SetMargins(leftAndRightMargin, topMarginPage, leftAndRightMargin)
-	pdf.SetAutoPageBreak(true, 2.0)
-
-	if watermarkR >= 0 {
-		pdf.SetFillColor(watermarkR, watermarkG, watermarkB)
-		pdf.Rect(0, 0, pageWidth, pageHeight, "F")
-	}
 
 	return pdf, err
 }
 
 //////////////////////////////////////////////////////////////////////////////////////////////////
-//Saving the pdf with a default name and location, which is overwritten if present in the recipe
+//Saving the pdf with a default name and location, which is overwritten if present in the recipe. With the recipe's "output" set to
+//"stdout" the pdf is written to standard output instead of a file
 func SavePDF(recipeFile PdfFields, pdf *gofpdf.Fpdf) error {
 	//Default location for the pdf to be saved and the name
 	pdfLocation := "/Users/oliverwoodcock/Documents/Testing and Sharing/go/go/src/Tutorials/PDF/"
@@ -639,8 +885,37 @@ func SavePDF(recipeFile PdfFields, pdf *gofpdf.Fpdf) error {
 		pdfFileName = recipeFile.PdfSettings.PdfName
 	}
 
-	pdfLocationToSaveAndName := pdfLocation + pdfFileName + ".pdf"
+	switch recipeFile.PdfSettings.Output {
+	case "stdout":
+		return WritePDF(pdf, os.Stdout)
+	case "", "file":
+	default:
+		return fmt.Errorf("unsupported output %q, expected file or stdout", recipeFile.PdfSettings.Output)
+	}
+
+	//Only creating the directories when the recipe asks for it, so a mistyped location doesn't quietly make new folders
+	if recipeFile.PdfSettings.CreateDirectories {
+		if err := os.MkdirAll(pdfLocation, 0755); err != nil {
+			return err
+		}
+	}
+
+	pdfLocationToSaveAndName := filepath.Join(pdfLocation, pdfFileName+".pdf")
 
 	return pdf.OutputFileAndClose(pdfLocationToSaveAndName)
 
 }
+
+//////////////////////////////////////////////////////////////////////////////////////////////////
+//Writing the pdf to any io.Writer, like an HTTP response or an upload to object storage. gofpdf closes the pdf once it's written, so it can only be written once
+func WritePDF(pdf *gofpdf.Fpdf, w io.Writer) error {
+	return pdf.Output(w)
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////
+//Writing the pdf into memory, for attaching to emails etc
+func PDFBytes(pdf *gofpdf.Fpdf) ([]byte, error) {
+	var pdfBuffer bytes.Buffer
+	err := WritePDF(pdf, &pdfBuffer)
+	return pdfBuffer.Bytes(), err
+}
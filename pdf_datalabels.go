@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Labels with each bar's value, written with their own font and format. The position is where the label goes against its bar:
+//
+//  "outside"  just above the top of the bar, moving inside the top of the bar when there's no room above it in the chart
+//  "inside"   just inside the top of the bar, moving above the bar when the bar is too short to hold it
+//  "centre"   in the middle of the bar
+//
+//Offset is the gap between the label and the top of the bar
+type DataLabels struct {
+	Show     bool        `json: show`
+	Position string      `json: position`
+	Offset   float64     `json: offset`
+	Font     Font        `json: font`
+	Format   ValueFormat `json: format`
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a chart's data label settings
+func ValidateDataLabels(dataLabels DataLabels) (err error) {
+
+	if !dataLabels.Show {
+		return nil
+	}
+	if dataLabels.Position != "outside" && dataLabels.Position != "inside" && dataLabels.Position != "centre" {
+		return fmt.Errorf("unsupported data label position %q, expected outside, inside or centre", dataLabels.Position)
+	}
+	if err = ValidateValueFormat(dataLabels.Format); err != nil {
+		return fmt.Errorf("data label format: %v", err)
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing a bar's value as its label. The bar runs up from baseline by barHeight, and plotTop is the highest a label can go
+func DrawBarDataLabel(pdf *gofpdf.Fpdf, dataLabels DataLabels, value float64, barXPosition, barWidth, baseline, barHeight, plotTop float64) {
+
+	font := dataLabels.Font
+	labelHeight := font.Size + font.LineSpacing
+	barTop := baseline - barHeight
+
+	//Working out where the top of the label goes
+	outsideYPosition := barTop - dataLabels.Offset - labelHeight
+	insideYPosition := barTop + dataLabels.Offset
+	labelYPosition := outsideYPosition
+	switch dataLabels.Position {
+	case "outside":
+		if outsideYPosition < plotTop && barHeight >= labelHeight+dataLabels.Offset {
+			labelYPosition = insideYPosition
+		}
+	case "inside":
+		if barHeight >= labelHeight+dataLabels.Offset {
+			labelYPosition = insideYPosition
+		}
+	case "centre":
+		labelYPosition = barTop + 0.5*(barHeight-labelHeight)
+	}
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+	pdf.SetXY(barXPosition, labelYPosition)
+	pdf.CellFormat(barWidth, labelHeight, TextForFont(pdf, font.Family, FormatValue(value, dataLabels.Format)), font.CellBorders.Style, 0, font.Alignment, font.CellFill.Filled, 0, "")
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//The documented defaults for the recipe. LoadRecipe merges these underneath everything else, so any setting that's left out of the recipe,
+//theme and named styles (or is set to null) falls back to the value here, while a setting that's written as 0, "" or false is kept.
+//
+//It has the same "pdfSettings", "styles", "itemDefaults" and "elementDefaults" sections as a theme, but its named styles are only
+//visible to the defaults
+const recipeDefaultsJSON = `{
+    "pdfSettings": {
+        "pageOrientation": "P",
+        "pageUnits": "pt",
+        "pageLeftAndRightMargins": 28.3,
+        "pageTopMargin": 42.5,
+        "pageBottomMargin": 2.0
+    },
+    "styles": {
+        "font": {
+            "size": 9.0,
+            "style": "",
+            "family": "Helvetica",
+            "alignment": "CM",
+            "lineSpacing": 3.0,
+            "colour": {"R": 0, "G": 0, "B": 0},
+            "cellFill": {"filled": false, "colour": {"R": 255, "G": 255, "B": 255}},
+            "cellBorders": {"style": "1", "colour": {"R": 0, "G": 0, "B": 0}},
+            "linkColour": {"R": 5, "G": 99, "B": 193}
+        },
+        "headerFont": {
+            "size": 10.0,
+            "style": "B",
+            "family": "Helvetica",
+            "alignment": "CM",
+            "lineSpacing": 3.0,
+            "colour": {"R": 0, "G": 0, "B": 0},
+            "cellFill": {"filled": false, "colour": {"R": 213, "G": 213, "B": 213}},
+            "cellBorders": {"style": "0", "colour": {"R": 0, "G": 0, "B": 0}}
+        },
+        "footerFont": {
+            "size": 9.0,
+            "style": "B",
+            "family": "Helvetica",
+            "alignment": "CM",
+            "lineSpacing": 3.0,
+            "colour": {"R": 0, "G": 0, "B": 0},
+            "cellFill": {"filled": true, "colour": {"R": 235, "G": 235, "B": 235}},
+            "cellBorders": {"style": "1", "colour": {"R": 0, "G": 0, "B": 0}}
+        },
+        "groupHeaderFont": {
+            "size": 9.0,
+            "style": "B",
+            "family": "Helvetica",
+            "alignment": "LM",
+            "lineSpacing": 3.0,
+            "colour": {"R": 0, "G": 0, "B": 0},
+            "cellFill": {"filled": false, "colour": {"R": 255, "G": 255, "B": 255}},
+            "cellBorders": {"style": "1", "colour": {"R": 0, "G": 0, "B": 0}}
+        },
+        "titleFont": {
+            "size": 10.0,
+            "style": "B",
+            "family": "Helvetica",
+            "alignment": "LM",
+            "lineSpacing": 3.0,
+            "colour": {"R": 0, "G": 0, "B": 0},
+            "cellFill": {"filled": false, "colour": {"R": 255, "G": 255, "B": 255}},
+            "cellBorders": {"style": "0", "colour": {"R": 0, "G": 0, "B": 0}}
+        },
+        "chartSettings": {
+            "numberOfYAxisTicks": 5.0,
+            "chartTextFont": {"styleName": "font"},
+            "chartTitle": {"font": {"styleName": "titleFont"}},
+            "dataLabels": {
+                "show": false,
+                "position": "outside",
+                "offset": 2.0,
+                "font": {"styleName": "font", "lineSpacing": 0.0, "cellBorders": {"style": "0"}}
+            },
+            "horizontalGridlines": {
+                "show": false,
+                "format": {"lineWidth": 0.3, "lineColour": {"R": 200, "G": 200, "B": 200}, "dashPattern": [2.0, 2.0]}
+            },
+            "verticalGridlines": {
+                "show": false,
+                "format": {"lineWidth": 0.3, "lineColour": {"R": 200, "G": 200, "B": 200}, "dashPattern": [2.0, 2.0]}
+            },
+            "xAxisTitle": {"font": {"styleName": "font", "lineSpacing": 1.0, "cellBorders": {"style": "0"}}},
+            "yAxisTitle": {"font": {"styleName": "font", "lineSpacing": 1.0, "cellBorders": {"style": "0"}}},
+            "xAxisLabelFit": "rotate",
+            "xAxisType": "category",
+            "yAxisType": "linear",
+            "palette": [
+                {"R": 228, "G": 155, "B": 185},
+                {"R": 102, "G": 52, "B": 115},
+                {"R": 230, "G": 137, "B": 50},
+                {"R": 70, "G": 130, "B": 180},
+                {"R": 60, "G": 160, "B": 110},
+                {"R": 150, "G": 150, "B": 150}
+            ],
+            "legend": {
+                "show": true,
+                "font": {
+                    "styleName": "font",
+                    "size": 7.0,
+                    "lineSpacing": 2.0,
+                    "cellBorders": {"style": "0"},
+                    "cellFill": {"filled": true, "colour": {"R": 255, "G": 255, "B": 255}}
+                }
+            }
+        },
+        "areaChartSettings": {
+            "styleName": "chartSettings",
+            "seriesFormat": {"fillColour": {"R": 228, "G": 155, "B": 185, "A": 0.6}, "lineWidth": 1.0},
+            "palette": [
+                {"R": 228, "G": 155, "B": 185, "A": 0.6},
+                {"R": 102, "G": 52, "B": 115, "A": 0.6},
+                {"R": 230, "G": 137, "B": 50, "A": 0.6},
+                {"R": 70, "G": 130, "B": 180, "A": 0.6},
+                {"R": 60, "G": 160, "B": 110, "A": 0.6},
+                {"R": 150, "G": 150, "B": 150, "A": 0.6}
+            ]
+        },
+        "comboChartSettings": {
+            "styleName": "chartSettings",
+            "seriesFormat": {"style": "F", "lineWidth": 1.5},
+            "markers": {"shape": "circle", "size": 3.0},
+            "secondaryYAxis": {
+                "type": "linear",
+                "numberOfTicks": 5.0,
+                "title": {"font": {"styleName": "font", "lineSpacing": 1.0, "cellBorders": {"style": "0"}}}
+            }
+        },
+        "scatterChartSettings": {
+            "styleName": "chartSettings",
+            "xAxisType": "linear",
+            "numberOfXAxisTicks": 5.0,
+            "seriesFormat": {"style": "FD", "fillColour": {"R": 228, "G": 155, "B": 185, "A": 0.7}, "borderColour": {"R": 102, "G": 52, "B": 115}},
+            "markers": {"shape": "circle", "size": 4.0, "maxSize": 30.0},
+            "pointLabels": {
+                "show": false,
+                "position": "above",
+                "offset": 2.0,
+                "font": {"styleName": "font", "lineSpacing": 0.0, "cellBorders": {"style": "0"}}
+            },
+            "trendLine": {
+                "show": false,
+                "format": {"lineWidth": 1.0, "lineColour": {"R": 102, "G": 52, "B": 115}, "dashPattern": [4.0, 2.0]},
+                "font": {"styleName": "font", "size": 7.0, "lineSpacing": 1.0, "cellBorders": {"style": "0"}},
+                "valueFormat": {"decimals": 2}
+            }
+        },
+        "sparklineSettings": {
+            "type": "line",
+            "format": {
+                "style": "F",
+                "fillColour": {"R": 228, "G": 155, "B": 185},
+                "borderColour": {"R": 102, "G": 52, "B": 115},
+                "lineWidth": 1.0,
+                "lineColour": {"R": 102, "G": 52, "B": 115}
+            },
+            "gapBetweenBars": 1.0,
+            "padding": 2.0,
+            "markerSize": 2.5,
+            "minPoint": {"show": false, "colour": {"R": 200, "G": 30, "B": 30}},
+            "maxPoint": {"show": false, "colour": {"R": 60, "G": 160, "B": 110}},
+            "lastPoint": {"show": false, "colour": {"R": 70, "G": 130, "B": 180}}
+        },
+        "heatmapSettings": {
+            "scale": "sequential",
+            "baseColour": {"R": 247, "G": 247, "B": 247},
+            "highColour": {"R": 102, "G": 52, "B": 115},
+            "lowColour": {"R": 230, "G": 137, "B": 50},
+            "cellFormat": {"style": "FD", "borderColour": {"R": 255, "G": 255, "B": 255}, "lineWidth": 0.5},
+            "labelFont": {"styleName": "font", "size": 7.0, "lineSpacing": 2.0, "cellBorders": {"style": "0"}},
+            "columnLabelFit": "rotate",
+            "valueLabels": {
+                "show": false,
+                "font": {"styleName": "font", "size": 6.0, "lineSpacing": 0.0, "cellBorders": {"style": "0"}}
+            },
+            "legend": {
+                "show": false,
+                "height": 6.0,
+                "font": {"styleName": "font", "size": 6.0, "lineSpacing": 1.0, "cellBorders": {"style": "0"}}
+            }
+        }
+    },
+    "itemDefaults": {
+        "textBlock": {
+            "font": {"styleName": "font"}
+        },
+        "table": {
+            "width": 100.0,
+            "font": {
+                "styleName": "font",
+                "headerFont": {"styleName": "headerFont"},
+                "footerFont": {"styleName": "footerFont"},
+                "groupHeaderFont": {"styleName": "groupHeaderFont"}
+            },
+            "tableSettings": {"subtotalLabel": "Subtotal", "totalLabel": "Total"}
+        },
+        "list": {
+            "width": 250.0,
+            "font": {"styleName": "font", "alignment": "LM", "cellBorders": {"style": "0"}},
+            "listSettings": {
+                "itemSpacing": 2.0,
+                "levels": [
+                    {"bullet": "•", "indent": 0.0, "hangingIndent": 14.0},
+                    {"bullet": "–", "indent": 14.0, "hangingIndent": 14.0},
+                    {"bullet": "•", "indent": 28.0, "hangingIndent": 14.0}
+                ]
+            }
+        },
+        "tableOfContents": {
+            "font": {
+                "styleName": "font",
+                "cellBorders": {"style": "0"},
+                "headerFont": {"styleName": "headerFont", "alignment": "LM"}
+            }
+        },
+        "verticalBar": {
+            "chartSettings": {"styleName": "chartSettings"}
+        },
+        "area": {
+            "chartSettings": {"styleName": "areaChartSettings"}
+        },
+        "stackedArea": {
+            "chartSettings": {"styleName": "areaChartSettings"}
+        },
+        "combo": {
+            "chartSettings": {"styleName": "comboChartSettings"}
+        },
+        "scatter": {
+            "chartSettings": {"styleName": "scatterChartSettings"}
+        },
+        "bubble": {
+            "chartSettings": {"styleName": "scatterChartSettings"}
+        },
+        "heatmap": {
+            "width": 300.0,
+            "height": 150.0,
+            "heatmapSettings": {"styleName": "heatmapSettings"}
+        },
+        "sparkline": {
+            "width": 80.0,
+            "height": 20.0,
+            "sparklineSettings": {"styleName": "sparklineSettings"}
+        }
+    },
+    "elementDefaults": {
+        "series": {"type": "bar", "axis": "primary"},
+        "columns": {"sparkline": {"styleName": "sparklineSettings"}},
+        "annotations": {
+            "format": {
+                "style": "F",
+                "fillColour": {"R": 228, "G": 155, "B": 185, "A": 0.25},
+                "lineWidth": 1.0,
+                "lineColour": {"R": 200, "G": 30, "B": 30},
+                "dashPattern": [4.0, 2.0]
+            },
+            "font": {"styleName": "font", "size": 7.0, "lineSpacing": 1.0, "cellBorders": {"style": "0"}}
+        }
+    }
+}`
+
+//////////////////////////////////////////////////////////////////////
+//Reading the defaults with their named styles already resolved
+func loadRecipeDefaults() (pdfSettings map[string]interface{}, itemDefaults map[string]interface{}, elementDefaults map[string]interface{}, err error) {
+
+	var defaults map[string]interface{}
+	if err = json.Unmarshal([]byte(recipeDefaultsJSON), &defaults); err != nil {
+		return nil, nil, nil, fmt.Errorf("reading recipe defaults: %v", err)
+	}
+
+	resolvedItemDefaults, err := resolveNamedStyles(jsonObject(defaults["itemDefaults"]), jsonObject(defaults["styles"]), nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading recipe defaults: %v", err)
+	}
+	resolvedElementDefaults, err := resolveNamedStyles(jsonObject(defaults["elementDefaults"]), jsonObject(defaults["styles"]), nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading recipe defaults: %v", err)
+	}
+
+	return jsonObject(defaults["pdfSettings"]), resolvedItemDefaults.(map[string]interface{}), resolvedElementDefaults.(map[string]interface{}), nil
+}
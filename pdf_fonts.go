@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//A TrueType/OpenType font family from the recipe's "fonts" section. Each variant is the path to a font file, only the regular one is required
+type FontFile struct {
+	Family     string `json: family`
+	Regular    string `json: regular`
+	Bold       string `json: bold`
+	Italic     string `json: italic`
+	BoldItalic string `json: boldItalic`
+}
+
+//The fonts built into gofpdf that can be used without registering them. These only cover the cp1252 character set
+var coreFontFamilies = map[string]bool{
+	"courier":      true,
+	"helvetica":    true,
+	"arial":        true,
+	"times":        true,
+	"symbol":       true,
+	"zapfdingbats": true,
+}
+
+//////////////////////////////////////////////////////////////////////
+//Registering the font files from the recipe as UTF-8 fonts, so that accented and non-Latin text prints correctly
+func RegisterFontsFromRecipe(pdf *gofpdf.Fpdf, recipeFile PdfFields) (err error) {
+
+	for _, fontFile := range recipeFile.Fonts {
+
+		if fontFile.Family == "" {
+			return fmt.Errorf("font registered without a family name")
+		}
+		if fontFile.Regular == "" {
+			return fmt.Errorf("font family %q has no regular font file", fontFile.Family)
+		}
+
+		//gofpdf's style strings for each of the variants
+		variants := map[string]string{
+			"":   fontFile.Regular,
+			"B":  fontFile.Bold,
+			"I":  fontFile.Italic,
+			"BI": fontFile.BoldItalic,
+		}
+		for style, fileName := range variants {
+			if fileName == "" {
+				continue
+			}
+			//Reading the file ourselves since gofpdf joins the path onto its font directory, which breaks absolute paths
+			fontBytes, readErr := ReadRecipeFile(fileName)
+			if readErr != nil {
+				return fmt.Errorf("reading font file for family %q: %v", fontFile.Family, readErr)
+			}
+			pdf.AddUTF8FontFromBytes(fontFile.Family, style, fontBytes)
+		}
+
+		//AddUTF8FontFromBytes doesn't return an error, it's stored on the pdf instead
+		if pdf.Err() {
+			return fmt.Errorf("registering font family %q: %v", fontFile.Family, pdf.Error())
+		}
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking that every font family and style used by the items in the recipe is either a core font or has been registered in the "fonts" section
+func ValidateFontsInRecipe(recipeFile PdfFields) (err error) {
+
+	//Registered family and style pairs, lower cased to match how gofpdf looks them up
+	registeredFonts := map[string]bool{}
+	for _, fontFile := range recipeFile.Fonts {
+		family := strings.ToLower(fontFile.Family)
+		registeredFonts[family] = fontFile.Regular != ""
+		registeredFonts[family+"B"] = fontFile.Bold != ""
+		registeredFonts[family+"I"] = fontFile.Italic != ""
+		registeredFonts[family+"BI"] = fontFile.BoldItalic != ""
+	}
+
+	checkFont := func(itemType, family, style string) error {
+		style = strings.ToUpper(style)
+		if strings.Trim(style, "BIU") != "" {
+			return fmt.Errorf("%s uses font style %q, expected a mix of B, I and U", itemType, style)
+		}
+		//Underlining is drawn over any font, so only the bold and italic part of the style needs a font file
+		fontStyle := ""
+		if strings.Contains(style, "B") {
+			fontStyle += "B"
+		}
+		if strings.Contains(style, "I") {
+			fontStyle += "I"
+		}
+		style = fontStyle
+		//An empty family falls back to the current font
+		if family == "" {
+			return nil
+		}
+		family = strings.ToLower(family)
+		if coreFontFamilies[family] {
+			return nil
+		}
+		if _, ok := registeredFonts[family]; !ok {
+			return fmt.Errorf("%s uses font family %q which isn't a core font and isn't registered in the recipe's fonts", itemType, family)
+		}
+		if !registeredFonts[family+style] {
+			return fmt.Errorf("%s uses font family %q with style %q but no font file is registered for that style", itemType, family, style)
+		}
+		return nil
+	}
+
+	for _, item := range recipeFile.PdfContents {
+		fontsToCheck := []struct {
+			family string
+			style  string
+		}{
+			{item.Font.Family, item.Font.Style},
+			{item.Font.HeaderFont.Family, item.Font.HeaderFont.Style},
+			{item.Font.FooterFont.Family, item.Font.FooterFont.Style},
+			{item.Font.GroupHeaderFont.Family, item.Font.GroupHeaderFont.Style},
+			{item.ChartSettings.ChartTextFont.Family, item.ChartSettings.ChartTextFont.Style},
+			{item.ChartSettings.ChartTitle.Font.Family, item.ChartSettings.ChartTitle.Font.Style},
+			{item.ChartSettings.DataLabels.Font.Family, item.ChartSettings.DataLabels.Font.Style},
+			{item.ChartSettings.XAxisTitle.Font.Family, item.ChartSettings.XAxisTitle.Font.Style},
+			{item.ChartSettings.YAxisTitle.Font.Family, item.ChartSettings.YAxisTitle.Font.Style},
+			{item.ChartSettings.PointLabels.Font.Family, item.ChartSettings.PointLabels.Font.Style},
+			{item.ChartSettings.TrendLine.Font.Family, item.ChartSettings.TrendLine.Font.Style},
+			{item.ChartSettings.Legend.Font.Family, item.ChartSettings.Legend.Font.Style},
+			{item.ChartSettings.SecondaryYAxis.Title.Font.Family, item.ChartSettings.SecondaryYAxis.Title.Font.Style},
+			{item.HeatmapSettings.LabelFont.Family, item.HeatmapSettings.LabelFont.Style},
+			{item.HeatmapSettings.ValueLabels.Font.Family, item.HeatmapSettings.ValueLabels.Font.Style},
+			{item.HeatmapSettings.Legend.Font.Family, item.HeatmapSettings.Legend.Font.Style},
+		}
+		//Table rules can change the style of the table's font
+		for _, column := range item.Columns {
+			for _, rule := range column.Rules {
+				if rule.FontStyle != nil {
+					fontsToCheck = append(fontsToCheck, struct {
+						family string
+						style  string
+					}{item.Font.Family, *rule.FontStyle})
+				}
+			}
+		}
+		for _, annotation := range item.ChartSettings.Annotations {
+			fontsToCheck = append(fontsToCheck, struct {
+				family string
+				style  string
+			}{annotation.Font.Family, annotation.Font.Style})
+		}
+		//Markup and links change the style part way through a text block or list entry
+		for _, style := range RichTextStylesForItem(item) {
+			fontsToCheck = append(fontsToCheck, struct {
+				family string
+				style  string
+			}{item.Font.Family, style})
+		}
+		for _, fontToCheck := range fontsToCheck {
+			if err = checkFont(item.ItemType, fontToCheck.family, fontToCheck.style); err != nil {
+				return err
+			}
+		}
+	}
+
+	return err
+}
+
+//Core fonts take cp1252 text rather than UTF-8, so symbols that the renderer adds itself, like bullets, are translated for them
+func TextForFont(pdf *gofpdf.Fpdf, family string, text string) string {
+	if !coreFontFamilies[strings.ToLower(family)] {
+		return text
+	}
+	return pdf.UnicodeTranslatorFromDescriptor("")(text)
+}
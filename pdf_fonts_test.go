@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFontsInRecipe(t *testing.T) {
+
+	//A registered family with only a regular and a bold file
+	fonts := []FontFile{{Family: "Noto", Regular: "noto.ttf", Bold: "noto-bold.ttf"}}
+	tests := []struct {
+		family    string
+		style     string
+		wantError string
+	}{
+		{"Helvetica", "BI", ""},
+		{"Helvetica", "U", ""},
+		{"helvetica", "bu", ""},
+		{"Noto", "", ""},
+		{"Noto", "BU", ""},
+		{"Noto", "U", ""},
+		{"Noto", "I", `with style "I" but no font file`},
+		{"Noto", "IU", `with style "I" but no font file`},
+		{"Missing", "", "isn't a core font"},
+		{"Helvetica", "X", `font style "X"`},
+	}
+
+	for _, test := range tests {
+		var recipe PdfFields
+		recipe.Fonts = fonts
+		recipe.PdfContents = []PdfContentItem{{ItemType: "textBlock", Font: Font{Family: test.family, Style: test.style}}}
+		err := ValidateFontsInRecipe(recipe)
+		if test.wantError == "" && err != nil {
+			t.Errorf("ValidateFontsInRecipe with %s %q returned an error: %v", test.family, test.style, err)
+		}
+		if test.wantError != "" && (err == nil || !strings.Contains(err.Error(), test.wantError)) {
+			t.Errorf("ValidateFontsInRecipe with %s %q returned %v, want an error containing %q", test.family, test.style, err, test.wantError)
+		}
+	}
+}
+
+func TestValidateFontsInRecipeChecksMarkupStyles(t *testing.T) {
+
+	fonts := []FontFile{{Family: "Noto", Regular: "noto.ttf", Italic: "noto-italic.ttf"}}
+	tests := []struct {
+		item      PdfContentItem
+		wantError string
+	}{
+		{PdfContentItem{ItemType: "textBlock", Markup: "html", Text: "<i>slanted</i> and <a href=\"#end\">linked</a>"}, ""},
+		{PdfContentItem{ItemType: "textBlock", Markup: "html", Text: "Some <b>bold</b> text"}, `with style "B" but no font file`},
+		{PdfContentItem{ItemType: "textBlock", Markup: "html", Text: "<em>Both <strong>at once</strong></em>"}, `with style "BI" but no font file`},
+		//Without markup the tags are written as they are
+		{PdfContentItem{ItemType: "textBlock", Text: "Some <b>bold</b> text"}, ""},
+		{PdfContentItem{ItemType: "list", Markup: "html", ListItems: []ListEntry{{Text: "one", Items: []ListEntry{{Text: "<b>nested</b>"}}}}}, `with style "B" but no font file`},
+		//The entries of a list from a data source could use any style
+		{PdfContentItem{ItemType: "list", Markup: "html", DataSource: "Notes"}, `with style "B" but no font file`},
+		{PdfContentItem{ItemType: "list", DataSource: "Notes"}, ""},
+	}
+
+	for _, test := range tests {
+		var recipe PdfFields
+		recipe.Fonts = fonts
+		test.item.Font = Font{Family: "Noto"}
+		recipe.PdfContents = []PdfContentItem{test.item}
+		err := ValidateFontsInRecipe(recipe)
+		if test.wantError == "" && err != nil {
+			t.Errorf("ValidateFontsInRecipe with %s %q returned an error: %v", test.item.ItemType, test.item.Text, err)
+		}
+		if test.wantError != "" && (err == nil || !strings.Contains(err.Error(), test.wantError)) {
+			t.Errorf("ValidateFontsInRecipe with %s %q returned %v, want an error containing %q", test.item.ItemType, test.item.Text, err, test.wantError)
+		}
+	}
+}
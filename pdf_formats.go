@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//How a value from the data is written in a table cell, axis label or data label. Everything is optional, an empty format writes
+//numbers as whole numbers and text as it is:
+//
+//  "decimals": 2                       places after the decimal point
+//  "thousands": true                   separate the thousands, 1,234,567
+//  "currency": "£"                     a currency symbol, before or after the number depending on the locale
+//  "percentage": true                  0.25 is written as 25%
+//  "abbreviate": true                  1500 is written as 1.5K, with M and B for millions and billions
+//  "prefix": "~", "suffix": " units"   text either side of the value
+//  "dateLayout": "Jan 2006"            text that's a date is rewritten in this Go time layout
+//  "dateInputLayout": "02/01/2006"     the layout dates are in the data, RFC 3339 and 2006-01-02 are tried without it
+//  "locale": "de"                      the separators and currency position for a locale, en when it isn't set
+//
+//decimalSeparator and thousandsSeparator override the locale's separators
+type ValueFormat struct {
+	Decimals           int    `json: decimals`
+	Thousands          bool   `json: thousands`
+	Currency           string `json: currency`
+	Percentage         bool   `json: percentage`
+	Abbreviate         bool   `json: abbreviate`
+	Prefix             string `json: prefix`
+	Suffix             string `json: suffix`
+	DateLayout         string `json: dateLayout`
+	DateInputLayout    string `json: dateInputLayout`
+	Locale             string `json: locale`
+	DecimalSeparator   string `json: decimalSeparator`
+	ThousandsSeparator string `json: thousandsSeparator`
+}
+
+//The separators for a locale and whether its currency symbol goes after the number
+type localeNumberFormat struct {
+	decimalSeparator   string
+	thousandsSeparator string
+	currencyAfter      bool
+}
+
+var localeNumberFormats = map[string]localeNumberFormat{
+	"":      {".", ",", false},
+	"en":    {".", ",", false},
+	"en-GB": {".", ",", false},
+	"en-US": {".", ",", false},
+	"ja":    {".", ",", false},
+	"de":    {",", ".", true},
+	"de-DE": {",", ".", true},
+	"de-CH": {".", "'", false},
+	"fr":    {",", " ", true},
+	"fr-FR": {",", " ", true},
+	"es":    {",", ".", true},
+	"it":    {",", ".", true},
+	"pt":    {",", ".", true},
+	"nl":    {",", ".", false},
+}
+
+//The most decimal places a format can have, beyond this floating point noise shows
+const maximumFormatDecimals = 10
+
+//////////////////////////////////////////////////////////////////////
+//Checking a format from the recipe
+func ValidateValueFormat(format ValueFormat) (err error) {
+
+	if format.Decimals < 0 || format.Decimals > maximumFormatDecimals {
+		return fmt.Errorf("format decimals %d should be between 0 and %d", format.Decimals, maximumFormatDecimals)
+	}
+	if _, ok := localeNumberFormats[format.Locale]; !ok {
+		return fmt.Errorf("unsupported format locale %q", format.Locale)
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing a value from the data using a format. Numbers get the number formatting, text gets the date formatting if the format has a
+//date layout and the text is a date, and anything else is written as it is
+func FormatValue(value interface{}, format ValueFormat) string {
+
+	switch typedValue := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return formatNumber(typedValue, format)
+	case string:
+		if format.DateLayout != "" {
+			if date, ok := parseDateValue(typedValue, format.DateInputLayout); ok {
+				return format.Prefix + date.Format(format.DateLayout) + format.Suffix
+			}
+		}
+		return typedValue
+	}
+
+	return fmt.Sprint(value)
+}
+
+//Turning text from the data into a date, using the input layout or the usual layouts if there isn't one
+func parseDateValue(text string, inputLayout string) (date time.Time, ok bool) {
+
+	layouts := []string{time.RFC3339, "2006-01-02"}
+	if inputLayout != "" {
+		layouts = []string{inputLayout}
+	}
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return date, true
+		}
+	}
+
+	return date, false
+}
+
+//Writing a number with the format's decimals, separators, scaling and symbols
+func formatNumber(number float64, format ValueFormat) string {
+
+	locale := localeNumberFormats[format.Locale]
+	decimalSeparator := locale.decimalSeparator
+	if format.DecimalSeparator != "" {
+		decimalSeparator = format.DecimalSeparator
+	}
+	thousandsSeparator := locale.thousandsSeparator
+	if format.ThousandsSeparator != "" {
+		thousandsSeparator = format.ThousandsSeparator
+	}
+
+	if format.Percentage {
+		number = number * 100
+	}
+
+	decimals := format.Decimals
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	//The number is rounded before the abbreviation is picked, so that 999.6 with no decimals is 1K rather than 1,000. A number that
+	//rounds up to 1000 of one abbreviation moves up to the next one, so that 999999 is 1M rather than 1000K
+	abbreviation := ""
+	if format.Abbreviate {
+		number = roundToDecimals(number, decimals)
+		abbreviations := []struct {
+			size   float64
+			letter string
+		}{{1e3, "K"}, {1e6, "M"}, {1e9, "B"}}
+		abbreviationIndex := -1
+		for i, scale := range abbreviations {
+			if math.Abs(number) >= scale.size {
+				abbreviationIndex = i
+			}
+		}
+		for abbreviationIndex >= 0 && abbreviationIndex < len(abbreviations)-1 && math.Abs(roundToDecimals(number/abbreviations[abbreviationIndex].size, decimals)) >= 1000 {
+			abbreviationIndex++
+		}
+		if abbreviationIndex >= 0 {
+			number = number / abbreviations[abbreviationIndex].size
+			abbreviation = abbreviations[abbreviationIndex].letter
+		}
+	}
+
+	digits := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	wholePart, fractionPart := digits, ""
+	if pointIndex := strings.Index(digits, "."); pointIndex >= 0 {
+		wholePart, fractionPart = digits[:pointIndex], digits[pointIndex+1:]
+	}
+
+	if format.Thousands {
+		var groupedWholePart strings.Builder
+		for i, digit := range wholePart {
+			if i > 0 && (len(wholePart)-i)%3 == 0 {
+				groupedWholePart.WriteString(thousandsSeparator)
+			}
+			groupedWholePart.WriteRune(digit)
+		}
+		wholePart = groupedWholePart.String()
+	}
+
+	formattedNumber := wholePart
+	if fractionPart != "" {
+		formattedNumber = formattedNumber + decimalSeparator + fractionPart
+	}
+	formattedNumber = formattedNumber + abbreviation
+	if format.Percentage {
+		formattedNumber = formattedNumber + "%"
+	}
+
+	if format.Currency != "" {
+		if locale.currencyAfter {
+			formattedNumber = formattedNumber + " " + format.Currency
+		} else {
+			formattedNumber = format.Currency + formattedNumber
+		}
+	}
+
+	//A number that rounds to zero isn't written as negative
+	if number < 0 && strings.Trim(digits, "0.") != "" {
+		formattedNumber = "-" + formattedNumber
+	}
+
+	return format.Prefix + formattedNumber + format.Suffix
+}
+
+//Rounding a number to a number of decimal places
+func roundToDecimals(number float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(number*scale) / scale
+}
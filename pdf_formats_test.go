@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestFormatValue(t *testing.T) {
+
+	tests := []struct {
+		value  interface{}
+		format ValueFormat
+		want   string
+	}{
+		{nil, ValueFormat{}, ""},
+		{1234.5, ValueFormat{}, "1234"},
+		{1234.5678, ValueFormat{Decimals: 2, Thousands: true}, "1,234.57"},
+		{1234567.0, ValueFormat{Thousands: true}, "1,234,567"},
+		{-1234.5, ValueFormat{Decimals: 1, Thousands: true, Currency: "£"}, "-£1,234.5"},
+		{1234.5, ValueFormat{Decimals: 2, Thousands: true, Currency: "€", Locale: "de"}, "1.234,50 €"},
+		{1234567.891, ValueFormat{Decimals: 2, Thousands: true, Locale: "fr"}, "1 234 567,89"},
+		{1234.5, ValueFormat{Decimals: 1, Thousands: true, DecimalSeparator: ",", ThousandsSeparator: "_"}, "1_234,5"},
+		{0.256, ValueFormat{Decimals: 1, Percentage: true}, "25.6%"},
+		{-0.004, ValueFormat{Decimals: 2}, "0.00"},
+		{42.0, ValueFormat{Prefix: "~", Suffix: " units"}, "~42 units"},
+
+		//Abbreviations are picked after rounding, so numbers just under a boundary move up to the next letter
+		{1500.0, ValueFormat{Decimals: 1, Abbreviate: true}, "1.5K"},
+		{2500000.0, ValueFormat{Decimals: 1, Abbreviate: true}, "2.5M"},
+		{3e9, ValueFormat{Abbreviate: true}, "3B"},
+		{999.0, ValueFormat{Abbreviate: true}, "999"},
+		{999.4, ValueFormat{Decimals: 2, Abbreviate: true}, "999.40"},
+		{999.6, ValueFormat{Abbreviate: true}, "1K"},
+		{999999.0, ValueFormat{Abbreviate: true}, "1M"},
+		{999950.0, ValueFormat{Decimals: 1, Abbreviate: true}, "1.0M"},
+		{-999999.0, ValueFormat{Abbreviate: true, Currency: "$"}, "-$1M"},
+		{2e12, ValueFormat{Abbreviate: true, Thousands: true}, "2,000B"},
+
+		//Text is written as it is unless it's a date and the format has a date layout
+		{"2024-03-05", ValueFormat{DateLayout: "02 Jan 2006"}, "05 Mar 2024"},
+		{"2024-03-05T10:30:00Z", ValueFormat{DateLayout: "Jan 2006"}, "Mar 2024"},
+		{"05/03/2024", ValueFormat{DateLayout: "2 January", DateInputLayout: "02/01/2006"}, "5 March"},
+		{"not a date", ValueFormat{DateLayout: "Jan 2006"}, "not a date"},
+		{"Brie", ValueFormat{Decimals: 2}, "Brie"},
+		{true, ValueFormat{}, "true"},
+	}
+
+	for _, test := range tests {
+		if got := FormatValue(test.value, test.format); got != test.want {
+			t.Errorf("FormatValue(%v, %+v) = %q, want %q", test.value, test.format, got, test.want)
+		}
+	}
+}
+
+func TestValidateValueFormat(t *testing.T) {
+
+	for _, format := range []ValueFormat{{Decimals: -1}, {Decimals: maximumFormatDecimals + 1}, {Locale: "xx"}} {
+		if err := ValidateValueFormat(format); err == nil {
+			t.Errorf("ValidateValueFormat(%+v) didn't return an error", format)
+		}
+	}
+	if err := ValidateValueFormat(ValueFormat{Decimals: 2, Locale: "de"}); err != nil {
+		t.Errorf("ValidateValueFormat returned an error for a valid format: %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Heatmaps draw a grid of cells, one row for each value of the rowKey and one column for each value of the columnKey, filled by the
+//valueKey on a colour scale. The rows and columns are in the order they first appear in the data, every point is one cell, and cells
+//that don't have a point are left empty. The row labels are down the left of the grid and the column labels are along the bottom,
+//laid out with columnLabelFit the same way as a chart's x axis labels when they don't fit
+//
+//  "itemType": "heatmap",
+//  "dataSource": "Website visits", "rowKey": "Weekday", "columnKey": "Hour", "valueKey": "Visits",
+//  "heatmapSettings": {"valueLabels": {"show": true}, "legend": {"show": true}}
+//
+//A sequential scale runs from the base colour at the lowest value to the high colour at the highest. A diverging scale runs from the
+//low colour up to the base colour at the midpoint, then on to the high colour, for values that go either way from a middle value.
+//The scale covers the data's values unless min and max are set, and the midpoint is halfway between them unless it's set
+type HeatmapSettings struct {
+	Scale          string             `json: scale`
+	BaseColour     Colour             `json: baseColour`
+	HighColour     Colour             `json: highColour`
+	LowColour      Colour             `json: lowColour`
+	Min            *float64           `json: min`
+	Max            *float64           `json: max`
+	Midpoint       *float64           `json: midpoint`
+	CellFormat     ShapeStyle         `json: cellFormat`
+	LabelFont      Font               `json: labelFont`
+	ColumnLabelFit string             `json: columnLabelFit`
+	ValueFormat    ValueFormat        `json: valueFormat`
+	ValueLabels    HeatmapValueLabels `json: valueLabels`
+	Legend         HeatmapLegend      `json: legend`
+}
+
+//Each cell's value written in the middle of it, in the heatmap's value format
+type HeatmapValueLabels struct {
+	Show bool `json: show`
+	Font Font `json: font`
+}
+
+//A bar under the heatmap shaded along the colour scale, with the values at its ends, and at the midpoint of a diverging scale.
+//Height is how tall the bar is
+type HeatmapLegend struct {
+	Show   bool    `json: show`
+	Height float64 `json: height`
+	Font   Font    `json: font`
+}
+
+//The ends and middle of a heatmap's colour scale
+type heatmapColourScale struct {
+	min      float64
+	midpoint float64
+	max      float64
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a heatmap's keys and settings
+func ValidateHeatmap(heatmapItem PdfContentItem) (err error) {
+
+	settings := heatmapItem.HeatmapSettings
+
+	if heatmapItem.RowKey == "" || heatmapItem.ColumnKey == "" || heatmapItem.ValueKey == "" {
+		return fmt.Errorf("heatmap for %q needs a rowKey, a columnKey and a valueKey", heatmapItem.DataSource)
+	}
+	if settings.Scale != "sequential" && settings.Scale != "diverging" {
+		return fmt.Errorf("unsupported heatmap scale %q, expected sequential or diverging", settings.Scale)
+	}
+	if settings.Min != nil && settings.Max != nil && *settings.Min >= *settings.Max {
+		return fmt.Errorf("heatmap min %v should be below its max %v", *settings.Min, *settings.Max)
+	}
+	switch settings.ColumnLabelFit {
+	case "rotate", "wrap", "fit":
+	default:
+		return fmt.Errorf("unsupported heatmap column label fit %q, expected rotate, wrap or fit", settings.ColumnLabelFit)
+	}
+	if settings.Legend.Show && settings.Legend.Height <= 0.0 {
+		return fmt.Errorf("heatmap legend height %v should be more than 0", settings.Legend.Height)
+	}
+
+	return ValidateValueFormat(settings.ValueFormat)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing heatmaps
+func ProcessHeatmapPDFItem(pdf *gofpdf.Fpdf, heatmapItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+
+	if err = ValidateHeatmap(heatmapItem); err != nil {
+		return err
+	}
+	settings := heatmapItem.HeatmapSettings
+
+	for _, dataset := range data {
+		if heatmapItem.DataSource == dataset.DataSource {
+
+			//Collecting the rows and columns in the order they first appear, and the value in each cell
+			var rowLabels, columnLabels []string
+			rowIndexes, columnIndexes := map[string]int{}, map[string]int{}
+			cellValues := map[[2]int]float64{}
+			var values []float64
+			for i, dataPoint := range dataset.DataPoints {
+				pointValues, _ := dataPoint.(map[string]interface{})
+				value, ok := pointValues[heatmapItem.ValueKey].(float64)
+				if !ok {
+					return fmt.Errorf("point %d in %q doesn't have a number for %q", i, heatmapItem.DataSource, heatmapItem.ValueKey)
+				}
+				rowLabel := FormatCellValue(pointValues[heatmapItem.RowKey])
+				columnLabel := FormatCellValue(pointValues[heatmapItem.ColumnKey])
+				if _, ok := rowIndexes[rowLabel]; !ok {
+					rowIndexes[rowLabel] = len(rowLabels)
+					rowLabels = append(rowLabels, rowLabel)
+				}
+				if _, ok := columnIndexes[columnLabel]; !ok {
+					columnIndexes[columnLabel] = len(columnLabels)
+					columnLabels = append(columnLabels, columnLabel)
+				}
+				cell := [2]int{rowIndexes[rowLabel], columnIndexes[columnLabel]}
+				if _, ok := cellValues[cell]; ok {
+					return fmt.Errorf("point %d in %q is a second value for row %q and column %q", i, heatmapItem.DataSource, rowLabel, columnLabel)
+				}
+				cellValues[cell] = value
+				values = append(values, value)
+			}
+			if len(values) == 0 {
+				return fmt.Errorf("heatmap data source %q has no points", heatmapItem.DataSource)
+			}
+			colourScale := HeatmapColourScale(settings, values)
+
+			//The row labels take as much room as the longest one needs, and the columns share the rest of the width
+			labelFont := settings.LabelFont
+			lineHeight := labelFont.Size + labelFont.LineSpacing
+			pdf.SetFont(labelFont.Family, labelFont.Style, labelFont.Size)
+			rowLabelWidth := 0.0
+			for i, label := range rowLabels {
+				rowLabels[i] = TextForFont(pdf, labelFont.Family, label)
+				rowLabelWidth = math.Max(rowLabelWidth, pdf.GetStringWidth(rowLabels[i])+2*pdf.GetCellMargin())
+			}
+			for i, label := range columnLabels {
+				columnLabels[i] = TextForFont(pdf, labelFont.Family, label)
+			}
+
+			boxX := heatmapItem.XPosition + pdfSettings.PdfSettings.PageLeftAndRightMargins
+			boxY := heatmapItem.YPosition + pdfSettings.PdfSettings.PageTopMargin
+			gridLeft := boxX + rowLabelWidth
+			gridRight := boxX + heatmapItem.Width
+			cellWidth := (gridRight - gridLeft) / float64(len(columnLabels))
+
+			//The column labels and the legend take their room from the bottom of the box
+			columnLabelLayout := CategoryLabelLayout(pdf, columnLabels, cellWidth, settings.ColumnLabelFit)
+			columnLabelHeight := heatmapColumnLabelHeight(pdf, columnLabels, columnLabelLayout, cellWidth, lineHeight)
+			legendHeight := 0.0
+			if settings.Legend.Show {
+				legendHeight = lineHeight + settings.Legend.Height + settings.Legend.Font.Size + settings.Legend.Font.LineSpacing
+			}
+			gridTop := boxY
+			gridBottom := boxY + heatmapItem.Height - columnLabelHeight - legendHeight
+			cellHeight := (gridBottom - gridTop) / float64(len(rowLabels))
+
+			//Drawing the cells, the empty ones only get their border
+			pdf.SetLineWidth(settings.CellFormat.LineWidth)
+			pdf.SetDrawColor(settings.CellFormat.BorderColour.R, settings.CellFormat.BorderColour.G, settings.CellFormat.BorderColour.B)
+			for rowIndex := range rowLabels {
+				for columnIndex := range columnLabels {
+					cellX := gridLeft + float64(columnIndex)*cellWidth
+					cellY := gridTop + float64(rowIndex)*cellHeight
+					value, ok := cellValues[[2]int{rowIndex, columnIndex}]
+					if !ok {
+						if strings.Contains(strings.ToUpper(settings.CellFormat.Style), "D") {
+							pdf.Rect(cellX, cellY, cellWidth, cellHeight, "D")
+						}
+						continue
+					}
+					colour := HeatmapCellColour(settings, colourScale, value)
+					pdf.SetFillColor(colour.R, colour.G, colour.B)
+					DrawWithAlpha(pdf, colour, func() {
+						pdf.Rect(cellX, cellY, cellWidth, cellHeight, settings.CellFormat.Style)
+					})
+					if settings.ValueLabels.Show {
+						valueFont := settings.ValueLabels.Font
+						pdf.SetFont(valueFont.Family, valueFont.Style, valueFont.Size)
+						pdf.SetTextColor(valueFont.Colour.R, valueFont.Colour.G, valueFont.Colour.B)
+						pdf.SetXY(cellX, cellY)
+						pdf.CellFormat(cellWidth, cellHeight, TextForFont(pdf, valueFont.Family, FormatValue(value, settings.ValueFormat)), "", 0, "CM", false, 0, "")
+					}
+				}
+			}
+
+			//Labelling the rows and columns
+			pdf.SetFont(labelFont.Family, labelFont.Style, labelFont.Size)
+			pdf.SetTextColor(labelFont.Colour.R, labelFont.Colour.G, labelFont.Colour.B)
+			for rowIndex, label := range rowLabels {
+				pdf.SetXY(boxX, gridTop+float64(rowIndex)*cellHeight)
+				pdf.CellFormat(rowLabelWidth, cellHeight, label, "", 0, "RM", false, 0, "")
+			}
+			for columnIndex, label := range columnLabels {
+				DrawCategoryLabel(pdf, label, columnLabelLayout, gridLeft+float64(columnIndex)*cellWidth, cellWidth, gridBottom, lineHeight)
+			}
+
+			if settings.Legend.Show {
+				DrawHeatmapLegend(pdf, settings, colourScale, gridLeft, gridBottom+columnLabelHeight+lineHeight, gridRight-gridLeft)
+			}
+		}
+	}
+
+	return err
+}
+
+//The room the column labels need under the grid, with the label font already set
+func heatmapColumnLabelHeight(pdf *gofpdf.Fpdf, labels []string, layout string, cellWidth, lineHeight float64) (height float64) {
+
+	height = lineHeight
+	for _, label := range labels {
+		switch layout {
+		case "rotate":
+			labelWidth := pdf.GetStringWidth(label) + 2*pdf.GetCellMargin()
+			angle := rotatedCategoryLabelAngle * math.Pi / 180.0
+			height = math.Max(height, labelWidth*math.Sin(angle)+lineHeight*math.Cos(angle))
+		case "wrap":
+			height = math.Max(height, float64(len(pdf.SplitText(label, cellWidth)))*lineHeight)
+		}
+	}
+
+	return height
+}
+
+//////////////////////////////////////////////////////////////////////
+//Working out the ends and middle of the colour scale from the values and the settings
+func HeatmapColourScale(settings HeatmapSettings, values []float64) (colourScale heatmapColourScale) {
+
+	colourScale.min, colourScale.max = valueRange(values)
+	if settings.Min != nil {
+		colourScale.min = *settings.Min
+	}
+	if settings.Max != nil {
+		colourScale.max = *settings.Max
+	}
+	colourScale.midpoint = 0.5 * (colourScale.min + colourScale.max)
+	if settings.Midpoint != nil {
+		colourScale.midpoint = *settings.Midpoint
+	}
+
+	return colourScale
+}
+
+//The colour of a value on the heatmap's colour scale. Values past the ends of the scale get the colour at that end
+func HeatmapCellColour(settings HeatmapSettings, colourScale heatmapColourScale, value float64) Colour {
+
+	//Where a value sits between two points on the scale, the middle when they're the same
+	position := func(from, to float64) float64 {
+		if to == from {
+			return 0.5
+		}
+		return (value - from) / (to - from)
+	}
+
+	if settings.Scale == "diverging" {
+		if value < colourScale.midpoint {
+			return BlendColours(settings.LowColour, settings.BaseColour, position(colourScale.min, colourScale.midpoint))
+		}
+		return BlendColours(settings.BaseColour, settings.HighColour, position(colourScale.midpoint, colourScale.max))
+	}
+
+	return BlendColours(settings.BaseColour, settings.HighColour, position(colourScale.min, colourScale.max))
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the colour scale legend as a shaded bar with its values written under it
+func DrawHeatmapLegend(pdf *gofpdf.Fpdf, settings HeatmapSettings, colourScale heatmapColourScale, x, y, width float64) {
+
+	legend := settings.Legend
+
+	//A diverging scale is shaded in two halves, meeting at the midpoint
+	midpointX := x + 0.5*width
+	if colourScale.max > colourScale.min {
+		midpointX = x + width*math.Max(0.0, math.Min(1.0, (colourScale.midpoint-colourScale.min)/(colourScale.max-colourScale.min)))
+	}
+	if settings.Scale == "diverging" {
+		drawLegendGradient(pdf, settings.LowColour, settings.BaseColour, x, y, midpointX-x, legend.Height)
+		drawLegendGradient(pdf, settings.BaseColour, settings.HighColour, midpointX, y, x+width-midpointX, legend.Height)
+	} else {
+		drawLegendGradient(pdf, settings.BaseColour, settings.HighColour, x, y, width, legend.Height)
+	}
+
+	font := legend.Font
+	labelHeight := font.Size + font.LineSpacing
+	labelY := y + legend.Height
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetXY(x, labelY)
+	pdf.CellFormat(0.5*width, labelHeight, TextForFont(pdf, font.Family, FormatValue(colourScale.min, settings.ValueFormat)), "", 0, "LT", false, 0, "")
+	pdf.SetXY(x+0.5*width, labelY)
+	pdf.CellFormat(0.5*width, labelHeight, TextForFont(pdf, font.Family, FormatValue(colourScale.max, settings.ValueFormat)), "", 0, "RT", false, 0, "")
+	if settings.Scale == "diverging" {
+		pdf.SetXY(midpointX-0.25*width, labelY)
+		pdf.CellFormat(0.5*width, labelHeight, TextForFont(pdf, font.Family, FormatValue(colourScale.midpoint, settings.ValueFormat)), "", 0, "CT", false, 0, "")
+	}
+}
+
+//Shading a box from one colour on the left to another on the right
+func drawLegendGradient(pdf *gofpdf.Fpdf, fromColour, toColour Colour, x, y, width, height float64) {
+	if width <= 0.0 {
+		return
+	}
+	pdf.LinearGradient(x, y, width, height, fromColour.R, fromColour.G, fromColour.B, toColour.R, toColour.G, toColour.B, 0, 0, 1, 0)
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestHeatmapColourScale(t *testing.T) {
+
+	values := []float64{3, 9, -1}
+	if colourScale := HeatmapColourScale(HeatmapSettings{}, values); colourScale != (heatmapColourScale{min: -1, midpoint: 4, max: 9}) {
+		t.Errorf("HeatmapColourScale from the values = %+v, want -1 to 9 with the midpoint at 4", colourScale)
+	}
+
+	min, midpoint := 0.0, 2.0
+	if colourScale := HeatmapColourScale(HeatmapSettings{Min: &min, Midpoint: &midpoint}, values); colourScale != (heatmapColourScale{min: 0, midpoint: 2, max: 9}) {
+		t.Errorf("HeatmapColourScale with a min and midpoint = %+v, want 0 to 9 with the midpoint at 2", colourScale)
+	}
+}
+
+func TestHeatmapCellColour(t *testing.T) {
+
+	low := Colour{R: 200, G: 0, B: 0, A: 1}
+	base := Colour{R: 255, G: 255, B: 255, A: 1}
+	high := Colour{R: 0, G: 0, B: 200, A: 1}
+
+	sequential := HeatmapSettings{Scale: "sequential", BaseColour: base, HighColour: high}
+	diverging := HeatmapSettings{Scale: "diverging", LowColour: low, BaseColour: base, HighColour: high}
+
+	tests := []struct {
+		settings    HeatmapSettings
+		colourScale heatmapColourScale
+		value       float64
+		want        Colour
+	}{
+		{sequential, heatmapColourScale{min: 0, midpoint: 50, max: 100}, 0, base},
+		{sequential, heatmapColourScale{min: 0, midpoint: 50, max: 100}, 100, high},
+		{sequential, heatmapColourScale{min: 0, midpoint: 50, max: 100}, 50, Colour{R: 128, G: 128, B: 228, A: 1}},
+		//Values past the ends of the scale get the colour at that end
+		{sequential, heatmapColourScale{min: 0, midpoint: 50, max: 100}, 150, high},
+		{sequential, heatmapColourScale{min: 0, midpoint: 50, max: 100}, -20, base},
+		//When every value is the same they're in the middle of the scale
+		{sequential, heatmapColourScale{min: 7, midpoint: 7, max: 7}, 7, Colour{R: 128, G: 128, B: 228, A: 1}},
+
+		{diverging, heatmapColourScale{min: -10, midpoint: 0, max: 10}, -10, low},
+		{diverging, heatmapColourScale{min: -10, midpoint: 0, max: 10}, -5, Colour{R: 228, G: 128, B: 128, A: 1}},
+		{diverging, heatmapColourScale{min: -10, midpoint: 0, max: 10}, 0, base},
+		{diverging, heatmapColourScale{min: -10, midpoint: 0, max: 10}, 5, Colour{R: 128, G: 128, B: 228, A: 1}},
+		{diverging, heatmapColourScale{min: -10, midpoint: 0, max: 10}, 10, high},
+		//A midpoint off centre stretches one side of the scale
+		{diverging, heatmapColourScale{min: -10, midpoint: 6, max: 10}, 8, Colour{R: 128, G: 128, B: 228, A: 1}},
+	}
+
+	for _, test := range tests {
+		if colour := HeatmapCellColour(test.settings, test.colourScale, test.value); colour != test.want {
+			t.Errorf("HeatmapCellColour(%s, %+v, %v) = %+v, want %+v", test.settings.Scale, test.colourScale, test.value, colour, test.want)
+		}
+	}
+}
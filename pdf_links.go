@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Link targets in the recipe and data can be:
+//
+//  "https://dashboards.example.com/sales"   an external URL, anything with a scheme like https:// or mailto:
+//  "#salesChart"                            the top of the item with "anchor": "salesChart"
+//  "#page=3"                                the top of page 3
+//
+//In a textBlock's text a link is written like markdown, "see the [sales dashboard](https://dashboards.example.com/sales)", or with
+//<a href="..."> when the block has "markup": "html"
+
+//Matches [link text](target) in text blocks
+var linkSpanPattern = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+
+//Internal links for one render of the pdf. Links to anchors are created as soon as they're used and pointed at the anchor's item once
+//it's been placed, so an item can link to an anchor that comes after it. The pages that links point at are kept so that they can be
+//checked once every page has been added
+type PdfAnchors struct {
+	links     map[string]int
+	placed    map[string]bool
+	pageLinks map[int]bool
+}
+
+func NewPdfAnchors() *PdfAnchors {
+	return &PdfAnchors{links: map[string]int{}, placed: map[string]bool{}, pageLinks: map[int]bool{}}
+}
+
+//The gofpdf link ID for an anchor, creating it the first time it's used
+func (anchors *PdfAnchors) LinkID(pdf *gofpdf.Fpdf, anchorName string) int {
+	linkID, ok := anchors.links[anchorName]
+	if !ok {
+		linkID = pdf.AddLink()
+		anchors.links[anchorName] = linkID
+	}
+	return linkID
+}
+
+//Pointing an anchor's link at a position on a page
+func (anchors *PdfAnchors) Place(pdf *gofpdf.Fpdf, anchorName string, page int, yPosition float64) {
+	pdf.SetLink(anchors.LinkID(pdf, anchorName), yPosition, page)
+	anchors.placed[anchorName] = true
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking every anchor that something links to was placed, a link to an anchor that doesn't exist would point nowhere
+func (anchors *PdfAnchors) CheckAllPlaced() error {
+
+	var missingAnchors []string
+	for anchorName := range anchors.links {
+		if !anchors.placed[anchorName] {
+			missingAnchors = append(missingAnchors, "#"+anchorName)
+		}
+	}
+	if len(missingAnchors) > 0 {
+		sort.Strings(missingAnchors)
+		return fmt.Errorf("links point to anchors that no item has: %s", strings.Join(missingAnchors, ", "))
+	}
+
+	return nil
+}
+
+//Checking every page that something links to is in the pdf, a link to a page past the end would point at a page that doesn't exist
+func (anchors *PdfAnchors) CheckPageLinks(pageCount int) error {
+
+	var missingPages []int
+	for pageNumber := range anchors.pageLinks {
+		if pageNumber > pageCount {
+			missingPages = append(missingPages, pageNumber)
+		}
+	}
+	if len(missingPages) > 0 {
+		sort.Ints(missingPages)
+		var links []string
+		for _, pageNumber := range missingPages {
+			links = append(links, fmt.Sprintf("#page=%d", pageNumber))
+		}
+		return fmt.Errorf("links point to pages past the end of the pdf, which has %d: %s", pageCount, strings.Join(links, ", "))
+	}
+
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Making an area of the page clickable, going to the link target
+func AddLinkArea(pdf *gofpdf.Fpdf, anchors *PdfAnchors, x, y, width, height float64, linkTarget string) (err error) {
+
+	if !strings.HasPrefix(linkTarget, "#") {
+		pdf.LinkString(x, y, width, height, linkTarget)
+		return nil
+	}
+
+	linkID, err := internalLinkID(pdf, anchors, linkTarget)
+	if err != nil {
+		return err
+	}
+	pdf.Link(x, y, width, height, linkID)
+
+	return nil
+}
+
+//The gofpdf link ID for a "#anchor" or "#page=N" target
+func internalLinkID(pdf *gofpdf.Fpdf, anchors *PdfAnchors, linkTarget string) (linkID int, err error) {
+
+	if strings.HasPrefix(linkTarget, "#page=") {
+		pageNumber, convErr := strconv.Atoi(strings.TrimPrefix(linkTarget, "#page="))
+		if convErr != nil || pageNumber < 1 {
+			return 0, fmt.Errorf("link %q should point at a page number from 1 upwards", linkTarget)
+		}
+		linkID = pdf.AddLink()
+		pdf.SetLink(linkID, 0, pageNumber)
+		anchors.pageLinks[pageNumber] = true
+		return linkID, nil
+	}
+
+	anchorName := strings.TrimPrefix(linkTarget, "#")
+	if anchorName == "" {
+		return 0, fmt.Errorf("link %q has no anchor name", linkTarget)
+	}
+
+	return anchors.LinkID(pdf, anchorName), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestInternalLinks(t *testing.T) {
+
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.AddPage()
+	anchors := NewPdfAnchors()
+
+	for _, linkTarget := range []string{"#page=1", "#page=2", "#page=5", "#page=9", "#page=5", "#summary", "#later"} {
+		if _, err := internalLinkID(pdf, anchors, linkTarget); err != nil {
+			t.Errorf("internalLinkID(%q) returned an error: %v", linkTarget, err)
+		}
+	}
+	for _, linkTarget := range []string{"#page=0", "#page=two", "#"} {
+		if _, err := internalLinkID(pdf, anchors, linkTarget); err == nil {
+			t.Errorf("internalLinkID(%q) didn't return an error", linkTarget)
+		}
+	}
+
+	//Page links are only checked against the pages once the pdf has been laid out
+	err := anchors.CheckPageLinks(pdf.PageCount())
+	if err == nil || !strings.Contains(err.Error(), "which has 2: #page=5, #page=9") {
+		t.Errorf("CheckPageLinks(2) returned %v, want an error listing pages 5 and 9", err)
+	}
+	if err := anchors.CheckPageLinks(9); err != nil {
+		t.Errorf("CheckPageLinks(9) returned an error: %v", err)
+	}
+
+	//Anchors can be linked to before they're placed, as long as an item places them in the end
+	anchors.Place(pdf, "summary", 1, 100)
+	err = anchors.CheckAllPlaced()
+	if err == nil || !strings.Contains(err.Error(), "#later") || strings.Contains(err.Error(), "#summary") {
+		t.Errorf("CheckAllPlaced returned %v, want an error for #later only", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//How a list looks at each level of nesting. A level is numbered when it has a numbering format, otherwise it uses its bullet.
+//The numbering format is the counter written as 1, a, A, i or I with whatever goes around it, "1.", "a)", "(i)", "A." etc. When the
+//text around it has one of those letters in it the counter goes in braces to pick it out, "Item {1}." or "Appendix {A}"
+//Indent is from the list's left edge to the level's marker, and the hanging indent is the gap from the marker to the text, which
+//wrapped lines line up with. Lists nested deeper than the levels given carry on with the deepest level, stepping in by the same amount
+type ListLevel struct {
+	Bullet        string  `json: bullet`
+	Numbering     string  `json: numbering`
+	Indent        float64 `json: indent`
+	HangingIndent float64 `json: hangingIndent`
+}
+
+//Settings for a list item. Levels given in the recipe replace the default levels as a whole, so each needs its own indents.
+//When the list comes from a data source, levelKey is an optional field in each row holding the row's level, starting from 0
+type ListSettings struct {
+	Levels      []ListLevel `json: levels`
+	ItemSpacing float64     `json: itemSpacing`
+	LevelKey    string      `json: levelKey`
+}
+
+//An entry in a static list, with the entries nested under it. Can be written as just the text for an entry with nothing nested
+type ListEntry struct {
+	Text  string      `json: text`
+	Items []ListEntry `json: items`
+}
+
+//An entry with its nesting flattened into a level
+type flatListEntry struct {
+	text  string
+	level int
+}
+
+//////////////////////////////////////////////////////////////////////
+//Accepting either "Sales are up" or {"text": "Sales are up", "items": [...]}
+func (entry *ListEntry) UnmarshalJSON(entryJSON []byte) error {
+
+	var entryText string
+	if json.Unmarshal(entryJSON, &entryText) == nil {
+		*entry = ListEntry{Text: entryText}
+		return nil
+	}
+
+	//A separate type so that unmarshalling the object doesn't call this method again
+	type listEntryObject ListEntry
+	var parsedEntry listEntryObject
+	if err := json.Unmarshal(entryJSON, &parsedEntry); err != nil {
+		return fmt.Errorf("list entry %s should be the entry text or an object with text and items", string(entryJSON))
+	}
+	*entry = ListEntry(parsedEntry)
+
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing a list. The entries are the item's listItems, or each row of the data source's dataSeries field. The text of each entry is
+//laid out like a text block, so it can have markup and links, and its wrapped lines hang under the text rather than the marker
+func ProcessListPDFItem(pdf *gofpdf.Fpdf, listItem PdfContentItem, pdfFields PdfFields, data Data, anchors *PdfAnchors) (err error) {
+
+	font := listItem.Font
+	settings := listItem.ListSettings
+	if len(settings.Levels) == 0 {
+		return fmt.Errorf("list has no levels in its listSettings")
+	}
+
+	entries, err := listEntriesForItem(listItem, data)
+	if err != nil {
+		return err
+	}
+
+	//Settings the x and y position for the list, and making position 0 equivalent to the margin that we've set
+	getXPosition := listItem.XPosition + pdfFields.PdfSettings.PageLeftAndRightMargins
+	getYPosition := listItem.YPosition + pdfFields.PdfSettings.PageTopMargin
+
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	lineHeight := font.Size + font.LineSpacing
+	cumulativeListHeight := 0.0
+	counters := []int{}
+
+	for i, entry := range entries {
+
+		level := listLevelAt(settings.Levels, entry.level)
+
+		//Counting entries at each level, a level's count starts again after anything shallower
+		for len(counters) <= entry.level {
+			counters = append(counters, 0)
+		}
+		counters[entry.level]++
+		counters = counters[:entry.level+1]
+
+		marker := level.Bullet
+		if level.Numbering != "" {
+			marker, err = FormatListNumber(level.Numbering, counters[entry.level])
+			if err != nil {
+				return err
+			}
+		}
+
+		textXPosition := getXPosition + level.Indent + level.HangingIndent
+		textWidth := listItem.Width - level.Indent - level.HangingIndent
+		if textWidth <= 0.0 {
+			return fmt.Errorf("list level %d is indented past the list's width", entry.level)
+		}
+
+		runs, parseErr := ParseRichText(entry.text, listItem.Markup)
+		if parseErr != nil {
+			return fmt.Errorf("list entry %d: %v", i, parseErr)
+		}
+		lines := LayoutRichText(pdf, font, runs, textWidth-2*pdf.GetCellMargin())
+		entryHeight := RichTextHeight(font, lines)
+
+		//If the list gets larger than the height set in the recipe, stop adding entries. A height of 0 means there's no limit
+		if listItem.Height > 0.0 && cumulativeListHeight+entryHeight > listItem.Height {
+			pdf.SetXY(getXPosition, getYPosition)
+			pdf.CellFormat(listItem.Width, 0.5*lineHeight, "...", "", 0, "CB", false, 0, "")
+			break
+		}
+
+		//The marker is right aligned against the text, so that numbers line up on their punctuation
+		pdf.SetFont(font.Family, font.Style, font.Size)
+		pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+		pdf.SetXY(getXPosition+level.Indent, getYPosition)
+		pdf.CellFormat(level.HangingIndent, lineHeight, TextForFont(pdf, font.Family, marker), "", 0, "RM", false, 0, "")
+
+		if drawErr := DrawRichText(pdf, anchors, font, lines, textXPosition, getYPosition, textWidth); drawErr != nil {
+			err = drawErr
+		}
+
+		getYPosition = getYPosition + entryHeight + settings.ItemSpacing
+		cumulativeListHeight = cumulativeListHeight + entryHeight + settings.ItemSpacing
+	}
+
+	return err
+}
+
+//Flattening the item's static entries, or reading them from its data source
+func listEntriesForItem(listItem PdfContentItem, data Data) (entries []flatListEntry, err error) {
+
+	if listItem.DataSource == "" {
+		var flatten func(items []ListEntry, level int)
+		flatten = func(items []ListEntry, level int) {
+			for _, item := range items {
+				entries = append(entries, flatListEntry{text: item.Text, level: level})
+				flatten(item.Items, level+1)
+			}
+		}
+		flatten(listItem.ListItems, 0)
+		return entries, nil
+	}
+
+	for _, dataset := range data {
+		if listItem.DataSource != dataset.DataSource {
+			continue
+		}
+		previousLevel := -1
+		for _, dataPoint := range dataset.DataPoints {
+			row, _ := dataPoint.(map[string]interface{})
+			level := 0
+			if listItem.ListSettings.LevelKey != "" {
+				levelValue, ok := row[listItem.ListSettings.LevelKey].(float64)
+				if !ok || levelValue < 0 || levelValue != math.Trunc(levelValue) {
+					return nil, fmt.Errorf("list row %v needs a whole number level of 0 or more in %q", row, listItem.ListSettings.LevelKey)
+				}
+				level = int(levelValue)
+			}
+			//Entries can only nest one level under the one before them
+			if level > previousLevel+1 {
+				return nil, fmt.Errorf("list row %v is at level %d, it can be at most level %d", row, level, previousLevel+1)
+			}
+			previousLevel = level
+			entries = append(entries, flatListEntry{text: FormatCellValue(row[listItem.DataSeries]), level: level})
+		}
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("no data source named %q", listItem.DataSource)
+}
+
+//The settings for a level of nesting, levels deeper than the ones given carry on from the deepest one
+func listLevelAt(levels []ListLevel, levelNumber int) ListLevel {
+
+	if levelNumber < len(levels) {
+		return levels[levelNumber]
+	}
+
+	deepestLevel := levels[len(levels)-1]
+	indentStep := deepestLevel.Indent
+	if len(levels) > 1 {
+		indentStep = deepestLevel.Indent - levels[len(levels)-2].Indent
+	}
+	deepestLevel.Indent = deepestLevel.Indent + float64(levelNumber-len(levels)+1)*indentStep
+
+	return deepestLevel
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing a list number in a numbering format. A counter in braces, {1}, {a}, {A}, {i} or {I}, is replaced by the count, otherwise the
+//first 1, a, A, i or I in the format is. The braces let the rest of the format have those letters in it, like the I in "Item {1}."
+func FormatListNumber(numbering string, count int) (string, error) {
+
+	var counterStart, counterEnd, counterIndex int
+	if counters := listCounterPattern.FindAllStringIndex(numbering, -1); len(counters) > 0 {
+		if len(counters) > 1 {
+			return "", fmt.Errorf("numbering format %q has more than one counter in braces", numbering)
+		}
+		counterStart, counterEnd = counters[0][0], counters[0][1]
+		counterIndex = counterStart + 1
+	} else {
+		counterIndex = strings.IndexAny(numbering, "1aAiI")
+		if counterIndex < 0 {
+			return "", fmt.Errorf("numbering format %q needs a 1, a, A, i or I for the number", numbering)
+		}
+		counterStart, counterEnd = counterIndex, counterIndex+1
+	}
+
+	var counterText string
+	switch numbering[counterIndex] {
+	case '1':
+		counterText = fmt.Sprint(count)
+	case 'a':
+		counterText = strings.ToLower(alphabeticListNumber(count))
+	case 'A':
+		counterText = alphabeticListNumber(count)
+	case 'i':
+		counterText = strings.ToLower(romanListNumber(count))
+	case 'I':
+		counterText = romanListNumber(count)
+	}
+
+	return numbering[:counterStart] + counterText + numbering[counterEnd:], nil
+}
+
+//The counter in a numbering format
+var listCounterPattern = regexp.MustCompile(`\{[1aAiI]\}`)
+
+//A, B ... Z, AA, AB ...
+func alphabeticListNumber(count int) (letters string) {
+	for count > 0 {
+		count--
+		letters = string(rune('A'+count%26)) + letters
+		count = count / 26
+	}
+	return letters
+}
+
+//I, II, III, IV ...
+func romanListNumber(count int) (numerals string) {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	for i, value := range values {
+		for count >= value {
+			numerals = numerals + symbols[i]
+			count = count - value
+		}
+	}
+	return numerals
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFormatListNumber(t *testing.T) {
+
+	tests := []struct {
+		numbering string
+		count     int
+		want      string
+	}{
+		{"1.", 3, "3."},
+		{"a)", 2, "b)"},
+		{"i.)", 3, "iii.)"},
+		{"(I)", 12, "(XII)"},
+		{"A.", 26, "Z."},
+		{"{1}.", 3, "3."},
+		{"{a})", 1, "a)"},
+		{"{a})", 27, "aa)"},
+		{"{A}.", 28, "AB."},
+		{"({i})", 4, "(iv)"},
+		{"{I}.", 1994, "MCMXCIV."},
+		{"Item {1}.", 2, "Item 2."},
+		{"Appendix {A}", 3, "Appendix C"},
+		{"Step {i} of 1a", 9, "Step ix of 1a"},
+	}
+
+	for _, test := range tests {
+		got, err := FormatListNumber(test.numbering, test.count)
+		if err != nil {
+			t.Errorf("FormatListNumber(%q, %d) returned an error: %v", test.numbering, test.count, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("FormatListNumber(%q, %d) = %q, want %q", test.numbering, test.count, got, test.want)
+		}
+	}
+}
+
+func TestFormatListNumberRejectsFormatsWithoutACounter(t *testing.T) {
+
+	for _, numbering := range []string{"", "-", "Step", "{x}.", "{1}.{a}"} {
+		if got, err := FormatListNumber(numbering, 1); err == nil {
+			t.Errorf("FormatListNumber(%q, 1) = %q, want an error", numbering, got)
+		}
+	}
+}
+
+func TestListEntriesForItem(t *testing.T) {
+
+	//Static entries are flattened with their nesting as the level
+	var listItem PdfContentItem
+	if err := json.Unmarshal([]byte(`{"listItems": ["one", {"text": "two", "items": ["two a", {"text": "two b", "items": ["deep"]}]}, "three"]}`), &listItem); err != nil {
+		t.Fatalf("reading the list item: %v", err)
+	}
+	entries, err := listEntriesForItem(listItem, nil)
+	if err != nil {
+		t.Fatalf("listEntriesForItem returned an error: %v", err)
+	}
+	want := []flatListEntry{{"one", 0}, {"two", 0}, {"two a", 1}, {"two b", 1}, {"deep", 2}, {"three", 0}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("listEntriesForItem = %+v, want %+v", entries, want)
+	}
+
+	//Rows from a data source can't skip a level
+	data := Data{{DataSource: "Notes", DataPoints: []interface{}{
+		map[string]interface{}{"note": "top", "level": 0.0},
+		map[string]interface{}{"note": "too deep", "level": 2.0},
+	}}}
+	listItem = PdfContentItem{DataSource: "Notes", DataSeries: "note", ListSettings: ListSettings{LevelKey: "level"}}
+	if _, err := listEntriesForItem(listItem, data); err == nil {
+		t.Errorf("listEntriesForItem didn't return an error for a row that skips a level")
+	}
+}
+
+func TestListLevelAt(t *testing.T) {
+
+	levels := []ListLevel{{Bullet: "-", Indent: 0}, {Bullet: "*", Indent: 15}, {Bullet: "+", Indent: 40}}
+	if level := listLevelAt(levels, 1); level != levels[1] {
+		t.Errorf("listLevelAt(levels, 1) = %+v, want %+v", level, levels[1])
+	}
+
+	//Deeper levels carry on stepping in by the gap between the last two levels
+	if level := listLevelAt(levels, 4); level.Bullet != "+" || level.Indent != 90 {
+		t.Errorf("listLevelAt(levels, 4) = %+v, want the deepest bullet indented by 90", level)
+	}
+}
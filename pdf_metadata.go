@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Document properties from the recipe's "metadata" section, these show in the pdf viewer's document properties and are what document
+//management systems index on. The creation date is RFC 3339 ("2020-03-31T09:00:00Z") or just the date ("2020-03-31")
+type Metadata struct {
+	Title        string `json: title`
+	Author       string `json: author`
+	Subject      string `json: subject`
+	Keywords     string `json: keywords`
+	Creator      string `json: creator`
+	CreationDate string `json: creationDate`
+}
+
+//An entry in the pdf's outline (the bookmarks sidebar) pointing at an item. Level 0 is the top level, each level below that is nested
+//under the closest bookmark before it with a lower level. Can be written as just the text for a top level bookmark
+type Bookmark struct {
+	Text  string `json: text`
+	Level int    `json: level`
+}
+
+//////////////////////////////////////////////////////////////////////
+//Accepting either "bookmark": "Sales" or "bookmark": {"text": "Sales", "level": 1}
+func (bookmark *Bookmark) UnmarshalJSON(bookmarkJSON []byte) error {
+
+	var bookmarkText string
+	if json.Unmarshal(bookmarkJSON, &bookmarkText) == nil {
+		*bookmark = Bookmark{Text: bookmarkText}
+		return nil
+	}
+
+	//A separate type so that unmarshalling the object doesn't call this method again
+	type bookmarkObject Bookmark
+	var parsedBookmark bookmarkObject
+	if err := json.Unmarshal(bookmarkJSON, &parsedBookmark); err != nil {
+		return fmt.Errorf("bookmark %s should be the bookmark text or an object with text and level", string(bookmarkJSON))
+	}
+	*bookmark = Bookmark(parsedBookmark)
+
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Setting the document properties from the recipe's metadata
+func ApplyMetadata(pdf *gofpdf.Fpdf, metadata Metadata) (err error) {
+
+	if metadata.Title != "" {
+		pdf.SetTitle(metadata.Title, true)
+	}
+	if metadata.Author != "" {
+		pdf.SetAuthor(metadata.Author, true)
+	}
+	if metadata.Subject != "" {
+		pdf.SetSubject(metadata.Subject, true)
+	}
+	if metadata.Keywords != "" {
+		pdf.SetKeywords(metadata.Keywords, true)
+	}
+	if metadata.Creator != "" {
+		pdf.SetCreator(metadata.Creator, true)
+	}
+
+	if metadata.CreationDate != "" {
+		creationDate, parseErr := time.Parse(time.RFC3339, metadata.CreationDate)
+		if parseErr != nil {
+			creationDate, parseErr = time.Parse("2006-01-02", metadata.CreationDate)
+		}
+		if parseErr != nil {
+			return fmt.Errorf("metadata creation date %q should be in the form 2006-01-02 or 2006-01-02T15:04:05Z07:00", metadata.CreationDate)
+		}
+		pdf.SetCreationDate(creationDate)
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking that the bookmark levels in the recipe nest properly, the outline breaks if a bookmark is more than one level deeper than the one before it
+func ValidateBookmarksInRecipe(recipeFile PdfFields) (err error) {
+
+	previousLevel := -1
+	for i, item := range recipeFile.PdfContents {
+		if item.Bookmark.Text == "" {
+			continue
+		}
+		if item.Bookmark.Level < 0 || item.Bookmark.Level > previousLevel+1 {
+			return fmt.Errorf("%s item %d has bookmark %q at level %d, it can be at most level %d", item.ItemType, i, item.Bookmark.Text, item.Bookmark.Level, previousLevel+1)
+		}
+		previousLevel = item.Bookmark.Level
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//What a text block with a height does when its text is taller than that height, set with "overflow" on the item:
+//
+//  ""          the text runs on past the height, as it always has
+//  "shrink"    the font size is stepped down until the text fits, the line spacing shrinks with it
+//  "ellipsis"  the lines that fit are kept and the last one is cut short with "…"
+//  "clip"      the text is cut off at the bottom of the box
+//  "error"     the item fails with an error saying how much room the text needed
+//
+//A height of 0 means the block has no height, so nothing overflows
+
+//The overflow policies a text block can have
+var textOverflowPolicies = map[string]bool{"": true, "shrink": true, "ellipsis": true, "clip": true, "error": true}
+
+//The smallest font size shrink will go to before giving up, anything smaller can't be read when printed
+const minimumShrinkFontSize = 4.0
+
+//How much shrink takes off the font size each time it tries again
+const shrinkFontSizeStep = 0.5
+
+//////////////////////////////////////////////////////////////////////
+//Laying out text to fit in a box using the overflow policy. Returns the font the text should be drawn in, which is only different
+//when it's been shrunk, the lines to draw and whether the drawing needs clipping to the box
+func FitRichTextToBox(pdf *gofpdf.Fpdf, font Font, runs []RichTextRun, width, height float64, overflow string) (fittedFont Font, lines []RichTextLine, clip bool, err error) {
+
+	if !textOverflowPolicies[overflow] {
+		return font, nil, false, fmt.Errorf("unsupported overflow %q, expected shrink, ellipsis, clip or error", overflow)
+	}
+
+	textWidth := width - 2*pdf.GetCellMargin()
+	lines = LayoutRichText(pdf, font, runs, textWidth)
+	textHeight := RichTextHeight(font, lines)
+	if height <= 0.0 || textHeight <= height {
+		return font, lines, false, nil
+	}
+
+	switch overflow {
+
+	case "shrink":
+		fittedFont = font
+		for RichTextHeight(fittedFont, lines) > height {
+			if fittedFont.Size-shrinkFontSizeStep < minimumShrinkFontSize {
+				return font, nil, false, fmt.Errorf("text doesn't fit in %.1f high even at font size %.1f", height, fittedFont.Size)
+			}
+			fittedFont.Size = fittedFont.Size - shrinkFontSizeStep
+			fittedFont.LineSpacing = font.LineSpacing * fittedFont.Size / font.Size
+			lines = LayoutRichText(pdf, fittedFont, runs, textWidth)
+		}
+		return fittedFont, lines, false, nil
+
+	case "ellipsis":
+		keptLines := 0
+		for keptLines < len(lines) && RichTextHeight(font, lines[:keptLines+1]) <= height {
+			keptLines++
+		}
+		if keptLines == 0 {
+			return font, nil, false, fmt.Errorf("not even one line of text fits in %.1f high", height)
+		}
+		lines = lines[:keptLines]
+		lines[keptLines-1] = truncateRichTextLine(pdf, font, lines[keptLines-1], textWidth)
+		return font, lines, false, nil
+
+	case "clip":
+		return font, lines, true, nil
+
+	case "error":
+		return font, nil, false, fmt.Errorf("text needs %.1f high but the block is only %.1f high", textHeight, height)
+	}
+
+	return font, lines, false, nil
+}
+
+//Cutting characters off the end of a line until there's room for an ellipsis after them, the ellipsis is in the style of the text
+//it follows. The line isn't justified since it's the last one
+func truncateRichTextLine(pdf *gofpdf.Fpdf, font Font, line RichTextLine, width float64) RichTextLine {
+
+	ellipsis := TextForFont(pdf, font.Family, "…")
+
+	//Copying the words so that cutting them down doesn't change the layout they came from
+	words := make([]richTextWord, len(line.words))
+	for i, word := range line.words {
+		words[i] = word
+		words[i].fragments = append([]richTextFragment{}, word.fragments...)
+	}
+
+	ellipsisWidth := func() float64 {
+		run := RichTextRun{}
+		if len(words) > 0 {
+			lastWord := words[len(words)-1]
+			run = lastWord.fragments[len(lastWord.fragments)-1].run
+		}
+		setRichTextFont(pdf, font, run)
+		return pdf.GetStringWidth(ellipsis)
+	}
+
+	for len(words) > 0 && richTextWordsWidth(words)+ellipsisWidth() > width {
+		lastWord := &words[len(words)-1]
+		lastFragment := &lastWord.fragments[len(lastWord.fragments)-1]
+		_, lastCharacterSize := utf8.DecodeLastRuneInString(lastFragment.text)
+		lastFragment.text = lastFragment.text[:len(lastFragment.text)-lastCharacterSize]
+		setRichTextFont(pdf, font, lastFragment.run)
+		lastFragment.width = pdf.GetStringWidth(lastFragment.text)
+
+		if lastFragment.text == "" {
+			lastWord.fragments = lastWord.fragments[:len(lastWord.fragments)-1]
+		}
+		lastWord.width = 0.0
+		for _, fragment := range lastWord.fragments {
+			lastWord.width = lastWord.width + fragment.width
+		}
+		if len(lastWord.fragments) == 0 {
+			words = words[:len(words)-1]
+		}
+	}
+
+	//The ellipsis goes on the end of the last fragment, or on its own if nothing's left of the line
+	ellipsisFragment := richTextFragment{text: ellipsis, width: ellipsisWidth()}
+	if len(words) == 0 {
+		words = append(words, richTextWord{})
+	} else {
+		lastWord := words[len(words)-1]
+		ellipsisFragment.run = lastWord.fragments[len(lastWord.fragments)-1].run
+	}
+	words[len(words)-1].fragments = append(words[len(words)-1].fragments, ellipsisFragment)
+	words[len(words)-1].width = words[len(words)-1].width + ellipsisFragment.width
+
+	return RichTextLine{words: words, width: richTextWordsWidth(words)}
+}
+
+//The width of a line's words, with the spaces between them
+func richTextWordsWidth(words []richTextWord) (width float64) {
+	for i, word := range words {
+		if i > 0 && word.hasSpace {
+			width = width + word.spaceWidth
+		}
+		width = width + word.width
+	}
+	return width
+}
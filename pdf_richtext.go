@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Text blocks with "markup": "html" can mix styles within the one block using a small set of HTML tags:
+//
+//  <b>bold</b> <i>italic</i> <u>underlined</u> <font color="#E49BB9">coloured</font> <span color="hotpink">also coloured</span>
+//  <a href="https://example.com">a link</a> line<br>break <p>a paragraph</p>
+//
+//<strong> and <em> work as well as <b> and <i>, colours can be anything a Colour accepts and HTML entities like &lt; are decoded.
+//Whatever the markup, line breaks in the text are kept and [link text](target) links work
+
+//Matches an opening, closing or self closing tag with its attributes
+var markupTagPattern = regexp.MustCompile(`<(/?)([a-zA-Z]+)((?:\s+[a-zA-Z-]+\s*=\s*"[^"]*")*)\s*/?>`)
+var markupAttributePattern = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*"([^"]*)"`)
+
+//A run of text that's all in the same style. Line and paragraph breaks are runs of their own with no text
+type RichTextRun struct {
+	Text           string
+	Bold           bool
+	Italic         bool
+	Underline      bool
+	Colour         *Colour
+	Link           string
+	LineBreak      bool
+	ParagraphBreak bool
+}
+
+//Part of a word in one style, a word can be made of several fragments when its style changes part way through
+type richTextFragment struct {
+	run   RichTextRun
+	text  string
+	width float64
+}
+
+//A word and the space in front of it, the space is only drawn when the word isn't the first on its line
+type richTextWord struct {
+	fragments  []richTextFragment
+	width      float64
+	spaceWidth float64
+	hasSpace   bool
+}
+
+//A laid out line. Lines that were wrapped can be stretched to the full width when the text is justified
+type RichTextLine struct {
+	words             []richTextWord
+	width             float64
+	wrapped           bool
+	paragraphGapAfter bool
+}
+
+//////////////////////////////////////////////////////////////////////
+//Parsing a text block's text into styled runs. Without markup only line breaks and [link text](target) links are picked out
+func ParseRichText(text string, markup string) (runs []RichTextRun, err error) {
+
+	if markup != "" && markup != "html" {
+		return nil, fmt.Errorf("unsupported markup %q, expected html", markup)
+	}
+
+	addText := func(plainText string, style RichTextRun) {
+		for i, line := range strings.Split(plainText, "\n") {
+			if i > 0 {
+				runs = append(runs, RichTextRun{LineBreak: true})
+			}
+			endOfLastLink := 0
+			for _, match := range linkSpanPattern.FindAllStringSubmatchIndex(line, -1) {
+				if match[0] > endOfLastLink {
+					run := style
+					run.Text = line[endOfLastLink:match[0]]
+					runs = append(runs, run)
+				}
+				linkRun := style
+				linkRun.Text = line[match[2]:match[3]]
+				linkRun.Link = line[match[4]:match[5]]
+				linkRun.Underline = true
+				runs = append(runs, linkRun)
+				endOfLastLink = match[1]
+			}
+			if endOfLastLink < len(line) {
+				run := style
+				run.Text = line[endOfLastLink:]
+				runs = append(runs, run)
+			}
+		}
+	}
+
+	if markup == "" {
+		addText(text, RichTextRun{})
+		return runs, nil
+	}
+
+	//Each open tag pushes the style it makes onto the stack, closing it pops back to the style underneath
+	type openTag struct {
+		name  string
+		style RichTextRun
+	}
+	tagStack := []openTag{{}}
+	paragraphBreak := func() {
+		if len(runs) > 0 && !runs[len(runs)-1].ParagraphBreak {
+			runs = append(runs, RichTextRun{ParagraphBreak: true})
+		}
+	}
+
+	endOfLastTag := 0
+	for _, match := range markupTagPattern.FindAllStringSubmatchIndex(text, -1) {
+
+		currentStyle := tagStack[len(tagStack)-1].style
+		addText(html.UnescapeString(text[endOfLastTag:match[0]]), currentStyle)
+		endOfLastTag = match[1]
+
+		isClosingTag := match[3] > match[2]
+		tagName := strings.ToLower(text[match[4]:match[5]])
+		attributes := map[string]string{}
+		for _, attribute := range markupAttributePattern.FindAllStringSubmatch(text[match[6]:match[7]], -1) {
+			attributes[strings.ToLower(attribute[1])] = html.UnescapeString(attribute[2])
+		}
+
+		if tagName == "br" {
+			runs = append(runs, RichTextRun{LineBreak: true})
+			continue
+		}
+
+		if isClosingTag {
+			//Closing a tag also closes any tags left open inside it
+			closed := false
+			for i := len(tagStack) - 1; i > 0; i-- {
+				if tagStack[i].name == tagName {
+					tagStack = tagStack[:i]
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("closing tag </%s> doesn't have an opening tag", tagName)
+			}
+			if tagName == "p" {
+				paragraphBreak()
+			}
+			continue
+		}
+
+		newStyle := currentStyle
+		switch tagName {
+		case "b", "strong":
+			newStyle.Bold = true
+		case "i", "em":
+			newStyle.Italic = true
+		case "u":
+			newStyle.Underline = true
+		case "font", "span":
+			if colourValue, ok := attributes["color"]; ok {
+				colour, colourErr := ParseColour(colourValue)
+				if colourErr != nil {
+					return nil, colourErr
+				}
+				newStyle.Colour = &colour
+			}
+		case "a":
+			if attributes["href"] == "" {
+				return nil, fmt.Errorf("<a> tag is missing its href")
+			}
+			newStyle.Link = attributes["href"]
+			newStyle.Underline = true
+		case "p":
+			paragraphBreak()
+		default:
+			return nil, fmt.Errorf("unsupported tag <%s>", tagName)
+		}
+		tagStack = append(tagStack, openTag{name: tagName, style: newStyle})
+	}
+	addText(html.UnescapeString(text[endOfLastTag:]), tagStack[len(tagStack)-1].style)
+
+	//A paragraph break at the very end would only add an empty gap
+	if len(runs) > 0 && runs[len(runs)-1].ParagraphBreak {
+		runs = runs[:len(runs)-1]
+	}
+
+	return runs, nil
+}
+
+//The font style a run is drawn in, the block's own style with the run's markup on top
+func richTextFontStyle(font Font, run RichTextRun) (fontStyle string) {
+	if run.Bold || strings.Contains(strings.ToUpper(font.Style), "B") {
+		fontStyle += "B"
+	}
+	if run.Italic || strings.Contains(strings.ToUpper(font.Style), "I") {
+		fontStyle += "I"
+	}
+	if run.Underline || strings.Contains(strings.ToUpper(font.Style), "U") {
+		fontStyle += "U"
+	}
+	return fontStyle
+}
+
+//The font styles an item's text can be drawn in once its markup is applied, so that they can be checked for font files before
+//anything is drawn. The entries of a list from a data source aren't known until then, so every style the markup can use is included
+func RichTextStylesForItem(item PdfContentItem) (styles []string) {
+
+	var texts []string
+	switch item.ItemType {
+	case "textBlock":
+		texts = []string{item.Text}
+	case "list":
+		if item.DataSource != "" {
+			if item.Markup != "" {
+				for _, run := range []RichTextRun{{Bold: true}, {Italic: true}, {Bold: true, Italic: true}} {
+					styles = append(styles, richTextFontStyle(item.Font, run))
+				}
+			}
+			return styles
+		}
+		var addEntries func(entries []ListEntry)
+		addEntries = func(entries []ListEntry) {
+			for _, entry := range entries {
+				texts = append(texts, entry.Text)
+				addEntries(entry.Items)
+			}
+		}
+		addEntries(item.ListItems)
+	}
+
+	for _, text := range texts {
+		//Markup that doesn't parse is reported when the item is drawn
+		runs, err := ParseRichText(text, item.Markup)
+		if err != nil {
+			continue
+		}
+		for _, run := range runs {
+			styles = append(styles, richTextFontStyle(item.Font, run))
+		}
+	}
+
+	return styles
+}
+
+//////////////////////////////////////////////////////////////////////
+//Breaking the runs into lines that fit the width. Words are measured in their own style, so a line can mix fonts and still wrap in
+//the right place. A word that's wider than the whole width is broken between characters
+func LayoutRichText(pdf *gofpdf.Fpdf, font Font, runs []RichTextRun, width float64) (lines []RichTextLine) {
+
+	//Splitting the runs into words, a fragment without a space before it carries on the word before it
+	var words []richTextWord
+	var breaks []RichTextRun
+	wordOpen := false
+	spaceBefore := false
+
+	//Breaks are kept as words with no fragments, with the break itself in breaks at the same index
+	for _, run := range runs {
+		if run.LineBreak || run.ParagraphBreak {
+			words = append(words, richTextWord{})
+			breaks = append(breaks, run)
+			wordOpen = false
+			spaceBefore = false
+			continue
+		}
+		//Core fonts take cp1252 rather than UTF-8, so the text is translated once here and then measured and drawn as it is
+		setRichTextFont(pdf, font, run)
+		for i, piece := range strings.Split(TextForFont(pdf, font.Family, run.Text), " ") {
+			if i > 0 {
+				spaceBefore = true
+			}
+			if piece == "" {
+				continue
+			}
+			if !wordOpen || spaceBefore {
+				words = append(words, richTextWord{hasSpace: spaceBefore, spaceWidth: pdf.GetStringWidth(" ")})
+				breaks = append(breaks, RichTextRun{})
+				wordOpen = true
+				spaceBefore = false
+			}
+			pieceWidth := pdf.GetStringWidth(piece)
+			words[len(words)-1].fragments = append(words[len(words)-1].fragments, richTextFragment{run: run, text: piece, width: pieceWidth})
+			words[len(words)-1].width += pieceWidth
+		}
+	}
+
+	lines = []RichTextLine{{}}
+	for i, word := range words {
+
+		if breaks[i].LineBreak || breaks[i].ParagraphBreak {
+			lines[len(lines)-1].paragraphGapAfter = breaks[i].ParagraphBreak
+			lines = append(lines, RichTextLine{})
+			continue
+		}
+
+		for _, wordPart := range splitRichTextWordToFit(pdf, font, word, width) {
+			currentLine := &lines[len(lines)-1]
+			spaceWidth := 0.0
+			if wordPart.hasSpace && len(currentLine.words) > 0 {
+				spaceWidth = wordPart.spaceWidth
+			}
+			if len(currentLine.words) > 0 && currentLine.width+spaceWidth+wordPart.width > width {
+				currentLine.wrapped = true
+				lines = append(lines, RichTextLine{})
+				currentLine = &lines[len(lines)-1]
+				spaceWidth = 0.0
+			}
+			if len(currentLine.words) == 0 {
+				wordPart.hasSpace = false
+			}
+			currentLine.words = append(currentLine.words, wordPart)
+			currentLine.width += spaceWidth + wordPart.width
+		}
+	}
+
+	return lines
+}
+
+//Breaking a word that's wider than the line into parts that fit, the first part keeps the word's space
+func splitRichTextWordToFit(pdf *gofpdf.Fpdf, font Font, word richTextWord, width float64) (wordParts []richTextWord) {
+
+	if word.width <= width {
+		return []richTextWord{word}
+	}
+
+	currentPart := richTextWord{hasSpace: word.hasSpace, spaceWidth: word.spaceWidth}
+	for _, fragment := range word.fragments {
+		setRichTextFont(pdf, font, fragment.run)
+		currentFragment := richTextFragment{run: fragment.run}
+		//Splitting on "" gives each UTF-8 character whole, and each byte of text that's already been translated for a core font
+		for _, character := range strings.Split(fragment.text, "") {
+			characterWidth := pdf.GetStringWidth(character)
+			if currentPart.width+characterWidth > width && (currentPart.width > 0.0 || currentFragment.width > 0.0) {
+				if currentFragment.text != "" {
+					currentPart.fragments = append(currentPart.fragments, currentFragment)
+				}
+				wordParts = append(wordParts, currentPart)
+				currentPart = richTextWord{}
+				currentFragment = richTextFragment{run: fragment.run}
+			}
+			currentFragment.text += character
+			currentFragment.width += characterWidth
+			currentPart.width += characterWidth
+		}
+		if currentFragment.text != "" {
+			currentPart.fragments = append(currentPart.fragments, currentFragment)
+		}
+	}
+	wordParts = append(wordParts, currentPart)
+
+	return wordParts
+}
+
+//////////////////////////////////////////////////////////////////////
+//The height that laid out lines take up
+func RichTextHeight(font Font, lines []RichTextLine) (height float64) {
+	lineHeight := font.Size + font.LineSpacing
+	for _, line := range lines {
+		height = height + lineHeight
+		if line.paragraphGapAfter {
+			height = height + 0.5*lineHeight
+		}
+	}
+	return height
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing laid out lines in the box that starts at x, y. The first letter of the font's alignment is used, L, C, R or J to justify.
+//The cell fill and borders go around the whole block, like MultiCell
+func DrawRichText(pdf *gofpdf.Fpdf, anchors *PdfAnchors, font Font, lines []RichTextLine, x, y, width float64) (err error) {
+
+	lineHeight := font.Size + font.LineSpacing
+	cellMargin := pdf.GetCellMargin()
+	textWidth := width - 2*cellMargin
+
+	//Fill and borders first so that the text is drawn on top
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+	filled := FillCellWithAlpha(pdf, font.CellFill.Colour, font.CellFill.Filled, x, y, width, RichTextHeight(font, lines))
+	pdf.SetXY(x, y)
+	pdf.CellFormat(width, RichTextHeight(font, lines), "", font.CellBorders.Style, 0, "", filled, 0, "")
+
+	//The words are placed exactly, so the cell margin is only used around the edge of the block
+	pdf.SetCellMargin(0)
+	defer pdf.SetCellMargin(cellMargin)
+
+	alignment := "L"
+	if font.Alignment != "" {
+		alignment = strings.ToUpper(font.Alignment[:1])
+	}
+
+	lineYPosition := y
+	for _, line := range lines {
+
+		wordXPosition := x + cellMargin
+		extraSpaceWidth := 0.0
+		switch alignment {
+		case "C":
+			wordXPosition = wordXPosition + (textWidth-line.width)/2
+		case "R":
+			wordXPosition = wordXPosition + textWidth - line.width
+		case "J":
+			//Only lines that wrapped are stretched, the last line of a paragraph stays as it is
+			spaces := 0
+			for _, word := range line.words {
+				if word.hasSpace {
+					spaces++
+				}
+			}
+			if line.wrapped && spaces > 0 {
+				extraSpaceWidth = (textWidth - line.width) / float64(spaces)
+			}
+		}
+
+		for _, word := range line.words {
+			if word.hasSpace {
+				wordXPosition = wordXPosition + word.spaceWidth + extraSpaceWidth
+			}
+			for _, fragment := range word.fragments {
+				setRichTextFont(pdf, font, fragment.run)
+				pdf.SetXY(wordXPosition, lineYPosition)
+
+				linkID, linkString := 0, ""
+				if strings.HasPrefix(fragment.run.Link, "#") {
+					var linkErr error
+					if linkID, linkErr = internalLinkID(pdf, anchors, fragment.run.Link); linkErr != nil {
+						err = linkErr
+					}
+				} else {
+					linkString = fragment.run.Link
+				}
+				pdf.CellFormat(fragment.width, lineHeight, fragment.text, "", 0, "LM", false, linkID, linkString)
+
+				wordXPosition = wordXPosition + fragment.width
+			}
+		}
+
+		lineYPosition = lineYPosition + lineHeight
+		if line.paragraphGapAfter {
+			lineYPosition = lineYPosition + 0.5*lineHeight
+		}
+	}
+
+	//Leaving the font as the block's own font
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+
+	return err
+}
+
+//Setting the font and colour for a run, layered on top of the block's font
+func setRichTextFont(pdf *gofpdf.Fpdf, font Font, run RichTextRun) {
+
+	pdf.SetFont(font.Family, richTextFontStyle(font, run), font.Size)
+
+	switch {
+	case run.Colour != nil:
+		pdf.SetTextColor(run.Colour.R, run.Colour.G, run.Colour.B)
+	case run.Link != "":
+		pdf.SetTextColor(font.LinkColour.R, font.LinkColour.G, font.LinkColour.B)
+	default:
+		pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	}
+}
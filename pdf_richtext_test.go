@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRichText(t *testing.T) {
+
+	pink := Colour{R: 255, G: 105, B: 180, A: 1.0}
+	tests := []struct {
+		text   string
+		markup string
+		want   []RichTextRun
+	}{
+		{"Plain text", "", []RichTextRun{{Text: "Plain text"}}},
+		{"Line one\nline two", "", []RichTextRun{{Text: "Line one"}, {LineBreak: true}, {Text: "line two"}}},
+		{"See [the site](https://example.com) now", "", []RichTextRun{
+			{Text: "See "}, {Text: "the site", Link: "https://example.com", Underline: true}, {Text: " now"},
+		}},
+		{"<b>Bold <i>both</i></b> plain", "html", []RichTextRun{
+			{Text: "Bold ", Bold: true}, {Text: "both", Bold: true, Italic: true}, {Text: " plain"},
+		}},
+		{`<strong>a</strong><em>b</em><u>c</u>`, "html", []RichTextRun{
+			{Text: "a", Bold: true}, {Text: "b", Italic: true}, {Text: "c", Underline: true},
+		}},
+		{`<span color="hotpink">pink</span> &lt;tag&gt;`, "html", []RichTextRun{
+			{Text: "pink", Colour: &pink}, {Text: " <tag>"},
+		}},
+		{`<a href="#summary">jump</a>`, "html", []RichTextRun{{Text: "jump", Link: "#summary", Underline: true}}},
+		{"one<br>two<br/>three", "html", []RichTextRun{
+			{Text: "one"}, {LineBreak: true}, {Text: "two"}, {LineBreak: true}, {Text: "three"},
+		}},
+		{"<p>First</p><p>Second</p>", "html", []RichTextRun{{Text: "First"}, {ParagraphBreak: true}, {Text: "Second"}}},
+		{"<b>closed by <i>the outer tag</b> plain", "html", []RichTextRun{
+			{Text: "closed by ", Bold: true}, {Text: "the outer tag", Bold: true, Italic: true}, {Text: " plain"},
+		}},
+	}
+
+	for _, test := range tests {
+		runs, err := ParseRichText(test.text, test.markup)
+		if err != nil {
+			t.Errorf("ParseRichText(%q, %q) returned an error: %v", test.text, test.markup, err)
+			continue
+		}
+		if !reflect.DeepEqual(runs, test.want) {
+			t.Errorf("ParseRichText(%q, %q) = %+v, want %+v", test.text, test.markup, runs, test.want)
+		}
+	}
+}
+
+func TestParseRichTextErrors(t *testing.T) {
+
+	tests := []struct {
+		text      string
+		markup    string
+		wantError string
+	}{
+		{"text", "markdown", "unsupported markup"},
+		{"a</b>", "html", "closing tag </b> doesn't have an opening tag"},
+		{"<table>a</table>", "html", "unsupported tag <table>"},
+		{"<a>link</a>", "html", "missing its href"},
+		{`<font color="notacolour">a</font>`, "html", "notacolour"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRichText(test.text, test.markup)
+		if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("ParseRichText(%q, %q) returned %v, want an error containing %q", test.text, test.markup, err, test.wantError)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+//The kinds of axis a chart can have, set with xAxisType and yAxisType:
+//
+//  "category"  the x axis of bar, area and combo charts, each category gets an equal width in the order of the data
+//  "time"      the categories are dates, placed along the x axis by their date so that gaps in the dates show as gaps
+//  "linear"    values on an evenly spaced scale, the y axis of every chart and the x axis of scatter and bubble charts
+//  "log"       values on a base 10 log scale with a tick at each power of ten, every value has to be above 0
+//
+//The dates on a time axis are read with the x axis label format's dateInputLayout, or as RFC 3339 or 2006-01-02 dates without it,
+//and they need to be in order. The ticks are every day, week, month or quarter, whichever is the smallest that doesn't crowd
+//the axis, and they're labelled with the x axis label format's dateLayout or a layout to suit the ticks
+var categoryXAxisTypes = map[string]bool{"category": true, "time": true}
+var valueAxisTypes = map[string]bool{"linear": true, "log": true}
+
+//The most ticks a time axis has before it moves up to a longer tick unit
+const maximumTimeAxisTicks = 12
+
+//The dates at each end of a time axis
+type TimeAxisRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+//////////////////////////////////////////////////////////////////////
+//Working out the value scale for a chart with a category x axis. A linear scale starts at 0 and is rounded up past the highest value,
+//with the chart's number of ticks. A log scale runs between the powers of ten either side of the values
+func CategoryValueScale(axisType string, ticks, minValue, maxValue float64) (scale AxisScale, err error) {
+
+	if axisType == "log" {
+		return LogAxisScale(minValue, maxValue)
+	}
+
+	scale.Max = GetMaxValueForAxisOnChart(maxValue)
+	//A chart of zeros still needs a scale to draw them against
+	if scale.Max <= 0.0 {
+		scale.Max = 1.0
+	}
+	scale.Step = scale.Max / (ticks - 1)
+
+	return scale, nil
+}
+
+//The smallest and largest of some values, for scaling an axis to them
+func valueRange(values []float64) (minValue, maxValue float64) {
+	for i, value := range values {
+		if i == 0 || value < minValue {
+			minValue = value
+		}
+		if i == 0 || value > maxValue {
+			maxValue = value
+		}
+	}
+	return minValue, maxValue
+}
+
+//Working out the scale for an axis of numbers, like the axes on a scatter chart
+func NumericAxisScale(axisType string, ticks, minValue, maxValue float64) (scale AxisScale, err error) {
+	if axisType == "log" {
+		return LogAxisScale(minValue, maxValue)
+	}
+	return NiceAxisScale(minValue, maxValue, ticks), nil
+}
+
+//A log scale from the power of ten below the smallest value to the power of ten above the largest
+func LogAxisScale(minValue, maxValue float64) (scale AxisScale, err error) {
+
+	if minValue <= 0.0 {
+		return scale, fmt.Errorf("a log axis needs every value to be above 0, the smallest is %v", minValue)
+	}
+
+	scale.Log = true
+	scale.Step = 10.0
+	scale.Min = math.Pow(10, math.Floor(math.Log10(minValue)))
+	scale.Max = math.Pow(10, math.Ceil(math.Log10(maxValue)))
+	if scale.Max <= scale.Min {
+		scale.Max = scale.Min * 10.0
+	}
+
+	return scale, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Reading the dates for a time axis from the categories
+func TimeAxisDates(categoryValues []interface{}, inputLayout string) (dates []time.Time, err error) {
+
+	for i, categoryValue := range categoryValues {
+		text, _ := categoryValue.(string)
+		date, ok := parseDateValue(text, inputLayout)
+		if !ok {
+			return nil, fmt.Errorf("category %d, %v, isn't a date for the time axis", i, categoryValue)
+		}
+		if i > 0 && !date.After(dates[i-1]) {
+			return nil, fmt.Errorf("category %d, %v, isn't after the category before it, the time axis needs its dates in order", i, categoryValue)
+		}
+		dates = append(dates, date)
+	}
+
+	return dates, nil
+}
+
+//Working out the ticks along a time axis and the layout for their labels
+func TimeAxisTicks(timeRange TimeAxisRange) (ticks []time.Time, labelLayout string) {
+
+	spanInDays := timeRange.End.Sub(timeRange.Start).Hours() / 24.0
+
+	//Trying each unit in turn until the ticks fit, quarters are spread out further when there are still too many
+	units := []struct {
+		name   string
+		days   float64
+		layout string
+	}{{"day", 1, "2 Jan"}, {"week", 7, "2 Jan"}, {"month", 30.44, "Jan 2006"}, {"quarter", 91.31, "quarter"}}
+	unit := units[len(units)-1]
+	for _, candidateUnit := range units {
+		if spanInDays/candidateUnit.days <= maximumTimeAxisTicks {
+			unit = candidateUnit
+			break
+		}
+	}
+	stride := int(math.Max(1, math.Ceil(spanInDays/unit.days/maximumTimeAxisTicks)))
+
+	//The first tick is at the start of the unit that the axis starts in
+	start := timeRange.Start
+	tick := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	switch unit.name {
+	case "week":
+		tick = tick.AddDate(0, 0, -((int(tick.Weekday()) + 6) % 7))
+	case "month":
+		tick = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	case "quarter":
+		tick = time.Date(start.Year(), ((start.Month()-1)/3)*3+1, 1, 0, 0, 0, 0, start.Location())
+	}
+
+	for !tick.After(timeRange.End) {
+		if !tick.Before(timeRange.Start) {
+			ticks = append(ticks, tick)
+		}
+		switch unit.name {
+		case "day":
+			tick = tick.AddDate(0, 0, stride)
+		case "week":
+			tick = tick.AddDate(0, 0, 7*stride)
+		case "month":
+			tick = tick.AddDate(0, stride, 0)
+		case "quarter":
+			tick = tick.AddDate(0, 3*stride, 0)
+		}
+	}
+
+	return ticks, unit.layout
+}
+
+//Writing a tick's date, "quarter" writes it as Q1 2006
+func formatTimeAxisTick(tick time.Time, labelLayout string) string {
+	if labelLayout == "quarter" {
+		return fmt.Sprintf("Q%d %d", (int(tick.Month())-1)/3+1, tick.Year())
+	}
+	return tick.Format(labelLayout)
+}
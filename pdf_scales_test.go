@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+//A date at midnight UTC, for building time axis ranges
+func testDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestTimeAxisTicks(t *testing.T) {
+
+	tests := []struct {
+		timeRange       TimeAxisRange
+		wantLayout      string
+		wantFirst       time.Time
+		wantLast        time.Time
+		wantTickCount   int
+		wantTickSpacing func(time.Time) time.Time
+	}{
+		//A week of days has a tick on every day
+		{TimeAxisRange{testDate(2024, 1, 1), testDate(2024, 1, 8)}, "2 Jan",
+			testDate(2024, 1, 1), testDate(2024, 1, 8), 8, func(tick time.Time) time.Time { return tick.AddDate(0, 0, 1) }},
+		//Weeks start on a Monday, and the Monday before the axis starts is left off
+		{TimeAxisRange{testDate(2024, 1, 3), testDate(2024, 3, 1)}, "2 Jan",
+			testDate(2024, 1, 8), testDate(2024, 2, 26), 8, func(tick time.Time) time.Time { return tick.AddDate(0, 0, 7) }},
+		{TimeAxisRange{testDate(2024, 1, 15), testDate(2024, 12, 20)}, "Jan 2006",
+			testDate(2024, 2, 1), testDate(2024, 12, 1), 11, func(tick time.Time) time.Time { return tick.AddDate(0, 1, 0) }},
+		//Five years of quarters is too many ticks, so they're spread out to every other quarter
+		{TimeAxisRange{testDate(2020, 1, 1), testDate(2024, 12, 31)}, "quarter",
+			testDate(2020, 1, 1), testDate(2024, 7, 1), 10, func(tick time.Time) time.Time { return tick.AddDate(0, 6, 0) }},
+	}
+
+	for _, test := range tests {
+		ticks, layout := TimeAxisTicks(test.timeRange)
+		if layout != test.wantLayout {
+			t.Errorf("TimeAxisTicks(%v) layout = %q, want %q", test.timeRange, layout, test.wantLayout)
+		}
+		if len(ticks) != test.wantTickCount || len(ticks) > maximumTimeAxisTicks {
+			t.Errorf("TimeAxisTicks(%v) = %d ticks, want %d", test.timeRange, len(ticks), test.wantTickCount)
+			continue
+		}
+		if !ticks[0].Equal(test.wantFirst) || !ticks[len(ticks)-1].Equal(test.wantLast) {
+			t.Errorf("TimeAxisTicks(%v) runs from %v to %v, want %v to %v", test.timeRange, ticks[0], ticks[len(ticks)-1], test.wantFirst, test.wantLast)
+		}
+		for i := 1; i < len(ticks); i++ {
+			if !ticks[i].Equal(test.wantTickSpacing(ticks[i-1])) {
+				t.Errorf("TimeAxisTicks(%v) tick %d is %v, after %v", test.timeRange, i, ticks[i], ticks[i-1])
+				break
+			}
+		}
+	}
+}
+
+func TestFormatTimeAxisTick(t *testing.T) {
+
+	if label := formatTimeAxisTick(testDate(2024, 8, 1), "quarter"); label != "Q3 2024" {
+		t.Errorf("formatTimeAxisTick as a quarter = %q, want %q", label, "Q3 2024")
+	}
+	if label := formatTimeAxisTick(testDate(2024, 8, 1), "Jan 2006"); label != "Aug 2024" {
+		t.Errorf("formatTimeAxisTick as a month = %q, want %q", label, "Aug 2024")
+	}
+}
+
+func TestTimeAxisDates(t *testing.T) {
+
+	dates, err := TimeAxisDates([]interface{}{"05/01/2024", "12/01/2024"}, "02/01/2006")
+	if err != nil {
+		t.Fatalf("TimeAxisDates returned an error: %v", err)
+	}
+	if !dates[0].Equal(testDate(2024, 1, 5)) || !dates[1].Equal(testDate(2024, 1, 12)) {
+		t.Errorf("TimeAxisDates = %v, want 5 and 12 January 2024", dates)
+	}
+
+	for _, categoryValues := range [][]interface{}{
+		{"2024-01-01", "not a date"},
+		{"2024-01-01", 20240102.0},
+		{"2024-02-01", "2024-01-01"},
+		{"2024-01-01", "2024-01-01"},
+	} {
+		if _, err := TimeAxisDates(categoryValues, ""); err == nil {
+			t.Errorf("TimeAxisDates(%v) didn't return an error", categoryValues)
+		}
+	}
+}
+
+func TestLogAxisScale(t *testing.T) {
+
+	tests := []struct {
+		minValue, maxValue float64
+		wantMin, wantMax   float64
+	}{
+		{3, 450, 1, 1000},
+		{0.05, 20, 0.01, 100},
+		{100, 100, 100, 1000},
+	}
+
+	for _, test := range tests {
+		scale, err := LogAxisScale(test.minValue, test.maxValue)
+		if err != nil {
+			t.Errorf("LogAxisScale(%v, %v) returned an error: %v", test.minValue, test.maxValue, err)
+			continue
+		}
+		if !scale.Log || scale.Min != test.wantMin || scale.Max != test.wantMax {
+			t.Errorf("LogAxisScale(%v, %v) = %+v, want %v to %v", test.minValue, test.maxValue, scale, test.wantMin, test.wantMax)
+		}
+	}
+
+	if _, err := LogAxisScale(0, 10); err == nil {
+		t.Errorf("LogAxisScale with a value of 0 didn't return an error")
+	}
+}
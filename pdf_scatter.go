@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Scatter and bubble charts plot each data point at its xKey and yKey values, against numeric x and y axes that are scaled to the
+//data, see NiceAxisScale, or on log axes with xAxisType and yAxisType. Bubble charts size each point's marker by its sizeKey value, so that the marker's area is in proportion
+//to the value, with the largest value getting a marker maxSize across. The markers are drawn in the series format. Line and band
+//annotations are drawn against the y axis, and computed lines use the points' y values. There are no categories for callouts
+//
+//  "itemType": "bubble",
+//  "dataSource": "Regions", "xKey": "Spend", "yKey": "Revenue", "sizeKey": "Customers", "labelKey": "Region",
+//  "chartSettings": {"markers": {"maxSize": 24}, "pointLabels": {"show": true}, "trendLine": {"show": true}}
+
+//The shape of the points on a scatter or bubble chart, size is how far across a scatter chart's markers are
+type Markers struct {
+	Shape   string  `json: shape`
+	Size    float64 `json: size`
+	MaxSize float64 `json: maxSize`
+}
+
+//The shapes a marker can be
+var markerShapes = map[string]bool{"circle": true, "square": true, "diamond": true, "triangle": true, "cross": true}
+
+//Labels next to each point, showing the point's labelKey value or its y value when there's no labelKey. The position is above,
+//below, left, right or centre, and the offset is the gap between the label and the marker
+type PointLabels struct {
+	Show     bool        `json: show`
+	Position string      `json: position`
+	Offset   float64     `json: offset`
+	Font     Font        `json: font`
+	Format   ValueFormat `json: format`
+}
+
+//A least squares regression line through the points, drawn across the range of the data. The label sits above the right hand end of
+//the line, and {slope}, {intercept} and {r2} in it are replaced with the fitted line's values written with valueFormat
+type TrendLine struct {
+	Show        bool        `json: show`
+	Format      ShapeStyle  `json: format`
+	Label       string      `json: label`
+	Font        Font        `json: font`
+	ValueFormat ValueFormat `json: valueFormat`
+}
+
+//A point read from the data
+type scatterPoint struct {
+	x     float64
+	y     float64
+	size  float64
+	label string
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a scatter or bubble chart's keys and settings
+func ValidateScatterChart(scatterItem PdfContentItem) (err error) {
+
+	chartSettings := scatterItem.ChartSettings
+
+	if scatterItem.XKey == "" || scatterItem.YKey == "" {
+		return fmt.Errorf("%s chart for %q needs an xKey and a yKey", scatterItem.ItemType, scatterItem.DataSource)
+	}
+	if scatterItem.ItemType == "bubble" && scatterItem.SizeKey == "" {
+		return fmt.Errorf("bubble chart for %q needs a sizeKey", scatterItem.DataSource)
+	}
+	if !valueAxisTypes[chartSettings.XAxisType] {
+		return fmt.Errorf("unsupported x axis type %q for a %s chart, expected linear or log", chartSettings.XAxisType, scatterItem.ItemType)
+	}
+	if chartSettings.NumberOfXAxisTicks < 2 || chartSettings.NumberOfYAxisTicks < 2 {
+		return fmt.Errorf("%s chart for %q needs at least 2 ticks on each axis", scatterItem.ItemType, scatterItem.DataSource)
+	}
+	if !markerShapes[chartSettings.Markers.Shape] {
+		return fmt.Errorf("unsupported marker shape %q, expected circle, square, diamond, triangle or cross", chartSettings.Markers.Shape)
+	}
+	if chartSettings.Markers.Size <= 0.0 || chartSettings.Markers.MaxSize <= 0.0 {
+		return fmt.Errorf("marker size %v and max size %v should be more than 0", chartSettings.Markers.Size, chartSettings.Markers.MaxSize)
+	}
+	if chartSettings.PointLabels.Show {
+		switch chartSettings.PointLabels.Position {
+		case "above", "below", "left", "right", "centre":
+		default:
+			return fmt.Errorf("unsupported point label position %q, expected above, below, left, right or centre", chartSettings.PointLabels.Position)
+		}
+	}
+
+	for i, annotation := range chartSettings.Annotations {
+		if annotation.Type == "callout" {
+			return fmt.Errorf("annotation %d is a callout, %s charts only have line and band annotations", i, scatterItem.ItemType)
+		}
+	}
+	if err = ValidateChartAnnotations(chartSettings.Annotations, nil); err != nil {
+		return err
+	}
+
+	for name, format := range map[string]ValueFormat{
+		"x axis label":     chartSettings.XAxisLabelFormat,
+		"y axis label":     chartSettings.YAxisLabelFormat,
+		"point label":      chartSettings.PointLabels.Format,
+		"trend line label": chartSettings.TrendLine.ValueFormat,
+	} {
+		if err = ValidateValueFormat(format); err != nil {
+			return fmt.Errorf("%s format: %v", name, err)
+		}
+	}
+
+	return ValidateChartAxes(chartSettings)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing scatter and bubble charts
+func ProcessScatterChartPDFItem(pdf *gofpdf.Fpdf, scatterItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+
+	if err = ValidateScatterChart(scatterItem); err != nil {
+		return err
+	}
+	chartSettings := scatterItem.ChartSettings
+
+	for _, dataset := range data {
+		if scatterItem.DataSource == dataset.DataSource {
+
+			points, err := scatterPointsFromData(scatterItem, dataset.DataPoints)
+			if err != nil {
+				return err
+			}
+
+			font := chartSettings.ChartTextFont
+
+			chartBoxX := scatterItem.XPosition + pdfSettings.PdfSettings.PageLeftAndRightMargins
+			chartBoxY := scatterItem.YPosition + pdfSettings.PdfSettings.PageTopMargin
+			plotLeft := chartBoxX + chartSettings.DistanceFromSidesOfChartArea
+			plotRight := chartBoxX + scatterItem.Width - chartSettings.DistanceFromSidesOfChartArea
+			plotTop := chartBoxY + chartSettings.DistanceFromTopOfChartArea
+			plotBottom := chartBoxY + scatterItem.Height - chartSettings.DistanceFromBottomOfChartArea
+			tickLength := chartSettings.TickMarkLength
+
+			//Scaling both axes to the data
+			minX, maxX, minY, maxY := points[0].x, points[0].x, points[0].y, points[0].y
+			for _, point := range points {
+				minX, maxX = math.Min(minX, point.x), math.Max(maxX, point.x)
+				minY, maxY = math.Min(minY, point.y), math.Max(maxY, point.y)
+			}
+			xScale, err := NumericAxisScale(chartSettings.XAxisType, chartSettings.NumberOfXAxisTicks, minX, maxX)
+			if err != nil {
+				return fmt.Errorf("x axis: %v", err)
+			}
+			yScale, err := NumericAxisScale(chartSettings.YAxisType, chartSettings.NumberOfYAxisTicks, minY, maxY)
+			if err != nil {
+				return fmt.Errorf("y axis: %v", err)
+			}
+
+			DrawChartBackground(pdf, chartSettings, chartBoxX, chartBoxY, scatterItem.Width, scatterItem.Height)
+
+			pdf.SetFont(font.Family, font.Style, font.Size)
+			pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+
+			//Drawing the y axis ticks, labels and gridlines
+			yAxisTitleWidth := YAxisTitleWidth(chartSettings.YAxisTitle)
+			for _, tickValue := range yScale.Ticks() {
+				tickYPosition := yScale.Position(tickValue, plotBottom, plotTop)
+				DrawGridline(pdf, chartSettings.HorizontalGridlines, plotLeft, tickYPosition, plotRight, tickYPosition)
+
+				pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+				pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+				pdf.Line(plotLeft, tickYPosition, plotLeft-tickLength, tickYPosition)
+				pdf.SetXY(chartBoxX+yAxisTitleWidth, tickYPosition-(0.5*font.Size))
+				pdf.CellFormat(chartSettings.DistanceFromSidesOfChartArea-tickLength-yAxisTitleWidth, font.Size, TextForFont(pdf, font.Family, yScale.TickLabel(tickValue, chartSettings.YAxisLabelFormat)), "", 0, "RM", false, 0, "")
+			}
+
+			//Drawing the x axis ticks, labels and gridlines, each label is centred on its tick
+			xTicks := xScale.Ticks()
+			xTickSpacing := (plotRight - plotLeft) / math.Max(1, float64(len(xTicks)-1))
+			for _, tickValue := range xTicks {
+				tickXPosition := xScale.Position(tickValue, plotLeft, plotRight)
+				DrawGridline(pdf, chartSettings.VerticalGridlines, tickXPosition, plotBottom, tickXPosition, plotTop)
+
+				pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+				pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+				pdf.Line(tickXPosition, plotBottom, tickXPosition, plotBottom+tickLength)
+				DrawCategoryLabel(pdf, TextForFont(pdf, font.Family, xScale.TickLabel(tickValue, chartSettings.XAxisLabelFormat)), "fit", tickXPosition-0.5*xTickSpacing, xTickSpacing, plotBottom+(0.5*tickLength), font.Size)
+			}
+
+			//Drawing the axis lines
+			pdf.SetLineWidth(chartSettings.AxisFormat.LineWidth)
+			pdf.SetDrawColor(chartSettings.AxisFormat.LineColour.R, chartSettings.AxisFormat.LineColour.G, chartSettings.AxisFormat.LineColour.B)
+			pdf.Line(plotLeft, plotTop, plotLeft, plotBottom)
+			pdf.Line(plotLeft, plotBottom, plotRight, plotBottom)
+
+			//The annotations only need the y axis of the plot area
+			annotationPlotArea := ChartPlotArea{ChartBoxX: chartBoxX, ChartBoxY: chartBoxY, Left: plotLeft, Right: plotRight, Top: plotTop, Bottom: plotBottom, ValueScale: yScale}
+			var yValues []float64
+			for _, point := range points {
+				yValues = append(yValues, point.y)
+			}
+			DrawChartBands(pdf, chartSettings.Annotations, annotationPlotArea)
+
+			//Working out how big each marker is. Bubbles are drawn from the biggest down so that the small ones aren't hidden
+			maxSize := 0.0
+			for _, point := range points {
+				maxSize = math.Max(maxSize, math.Abs(point.size))
+			}
+			markerSizes := make([]float64, len(points))
+			drawOrder := make([]int, len(points))
+			for i, point := range points {
+				drawOrder[i] = i
+				markerSizes[i] = chartSettings.Markers.Size
+				if scatterItem.ItemType == "bubble" {
+					markerSizes[i] = 0.0
+					if maxSize > 0.0 {
+						markerSizes[i] = chartSettings.Markers.MaxSize * math.Sqrt(math.Abs(point.size)/maxSize)
+					}
+				}
+			}
+			sort.SliceStable(drawOrder, func(i, j int) bool {
+				return markerSizes[drawOrder[i]] > markerSizes[drawOrder[j]]
+			})
+
+			//Drawing the markers, kept inside the chart box so that big bubbles don't spill onto the rest of the page
+			pdf.ClipRect(chartBoxX, chartBoxY, scatterItem.Width, scatterItem.Height, false)
+			for _, i := range drawOrder {
+				DrawMarker(pdf, chartSettings.Markers.Shape, chartSettings.SeriesFormat, xScale.Position(points[i].x, plotLeft, plotRight), yScale.Position(points[i].y, plotBottom, plotTop), markerSizes[i])
+			}
+			pdf.ClipEnd()
+
+			//Drawing the trend line
+			if chartSettings.TrendLine.Show {
+				DrawTrendLine(pdf, chartSettings.TrendLine, points, xScale, yScale, plotLeft, plotRight, plotTop, plotBottom)
+			}
+
+			DrawChartAnnotations(pdf, chartSettings.Annotations, annotationPlotArea, nil, yValues)
+
+			//Labelling the points
+			if chartSettings.PointLabels.Show {
+				for i, point := range points {
+					DrawPointLabel(pdf, chartSettings.PointLabels, point.label, xScale.Position(point.x, plotLeft, plotRight), yScale.Position(point.y, plotBottom, plotTop), markerSizes[i])
+				}
+			}
+
+			//Adding the chart and axis titles
+			DrawChartTitle(pdf, chartSettings, chartBoxX, chartBoxY, scatterItem.Width)
+			DrawXAxisTitle(pdf, chartSettings.XAxisTitle, chartBoxX, scatterItem.Width, chartBoxY+scatterItem.Height)
+			DrawYAxisTitle(pdf, chartSettings.YAxisTitle, chartBoxX, plotTop, plotBottom)
+		}
+	}
+
+	return err
+}
+
+//Reading the points from the data source, every point needs numbers for its x and y, and its size on a bubble chart
+func scatterPointsFromData(scatterItem PdfContentItem, dataPoints []interface{}) (points []scatterPoint, err error) {
+
+	for i, dataPoint := range dataPoints {
+		values, _ := dataPoint.(map[string]interface{})
+		point := scatterPoint{}
+		var ok bool
+		if point.x, ok = values[scatterItem.XKey].(float64); !ok {
+			return nil, fmt.Errorf("point %d in %q doesn't have a number for %q", i, scatterItem.DataSource, scatterItem.XKey)
+		}
+		if point.y, ok = values[scatterItem.YKey].(float64); !ok {
+			return nil, fmt.Errorf("point %d in %q doesn't have a number for %q", i, scatterItem.DataSource, scatterItem.YKey)
+		}
+		if scatterItem.ItemType == "bubble" {
+			if point.size, ok = values[scatterItem.SizeKey].(float64); !ok {
+				return nil, fmt.Errorf("point %d in %q doesn't have a number for %q", i, scatterItem.DataSource, scatterItem.SizeKey)
+			}
+		}
+		if scatterItem.LabelKey != "" {
+			point.label = FormatValue(values[scatterItem.LabelKey], scatterItem.ChartSettings.PointLabels.Format)
+		} else {
+			point.label = FormatValue(point.y, scatterItem.ChartSettings.PointLabels.Format)
+		}
+		points = append(points, point)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%s chart data source %q has no points", scatterItem.ItemType, scatterItem.DataSource)
+	}
+
+	return points, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing a marker centred on a point, size across, in the shape's fill and border
+func DrawMarker(pdf *gofpdf.Fpdf, shape string, format ShapeStyle, x, y, size float64) {
+
+	radius := 0.5 * size
+	if radius <= 0.0 {
+		return
+	}
+
+	pdf.SetFillColor(format.FillColour.R, format.FillColour.G, format.FillColour.B)
+	pdf.SetDrawColor(format.BorderColour.R, format.BorderColour.G, format.BorderColour.B)
+
+	DrawWithAlpha(pdf, format.FillColour, func() {
+		switch shape {
+		case "circle":
+			pdf.Circle(x, y, radius, format.Style)
+		case "square":
+			pdf.Rect(x-radius, y-radius, size, size, format.Style)
+		case "diamond":
+			pdf.Polygon([]gofpdf.PointType{{X: x, Y: y - radius}, {X: x + radius, Y: y}, {X: x, Y: y + radius}, {X: x - radius, Y: y}}, format.Style)
+		case "triangle":
+			pdf.Polygon([]gofpdf.PointType{{X: x, Y: y - radius}, {X: x + radius, Y: y + radius}, {X: x - radius, Y: y + radius}}, format.Style)
+		case "cross":
+			//A cross has no inside to fill, so it's drawn in the border colour
+			pdf.Line(x-radius, y-radius, x+radius, y+radius)
+			pdf.Line(x-radius, y+radius, x+radius, y-radius)
+		}
+	})
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the regression line through the points, from the smallest x value to the largest, clipped to the plot area. It's drawn in
+//short pieces so that it bends the way it should on a log axis
+func DrawTrendLine(pdf *gofpdf.Fpdf, trendLine TrendLine, points []scatterPoint, xScale, yScale AxisScale, plotLeft, plotRight, plotTop, plotBottom float64) {
+
+	slope, intercept, rSquared, ok := FitLinearTrend(points)
+	if !ok {
+		return
+	}
+
+	minX, maxX := points[0].x, points[0].x
+	for _, point := range points {
+		minX, maxX = math.Min(minX, point.x), math.Max(maxX, point.x)
+	}
+	const trendLinePieces = 50
+	var line []gofpdf.PointType
+	for i := 0; i <= trendLinePieces; i++ {
+		x := minX + (maxX-minX)*float64(i)/trendLinePieces
+		line = append(line, gofpdf.PointType{X: xScale.Position(x, plotLeft, plotRight), Y: yScale.Position(slope*x+intercept, plotBottom, plotTop)})
+	}
+	endX, endY := line[trendLinePieces].X, line[trendLinePieces].Y
+
+	pdf.ClipRect(plotLeft, plotTop, plotRight-plotLeft, plotBottom-plotTop, false)
+	pdf.SetLineWidth(trendLine.Format.LineWidth)
+	pdf.SetDrawColor(trendLine.Format.LineColour.R, trendLine.Format.LineColour.G, trendLine.Format.LineColour.B)
+	pdf.SetDashPattern(trendLine.Format.DashPattern, 0)
+	DrawWithAlpha(pdf, trendLine.Format.LineColour, func() {
+		for i := 1; i < len(line); i++ {
+			pdf.Line(line[i-1].X, line[i-1].Y, line[i].X, line[i].Y)
+		}
+	})
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.ClipEnd()
+
+	if trendLine.Label == "" {
+		return
+	}
+	label := strings.NewReplacer(
+		"{slope}", FormatValue(slope, trendLine.ValueFormat),
+		"{intercept}", FormatValue(intercept, trendLine.ValueFormat),
+		"{r2}", FormatValue(rSquared, trendLine.ValueFormat),
+	).Replace(trendLine.Label)
+	labelHeight := trendLine.Font.Size + trendLine.Font.LineSpacing
+	labelYPosition := math.Max(plotTop, math.Min(plotBottom, endY)-labelHeight)
+	drawAnnotationLabel(pdf, trendLine.Font, label, plotLeft, labelYPosition, endX-plotLeft, "RB")
+}
+
+//Fitting a straight line through the points by least squares. It can't be fitted when every point has the same x value
+func FitLinearTrend(points []scatterPoint) (slope, intercept, rSquared float64, ok bool) {
+
+	n := float64(len(points))
+	sumX, sumY, sumXX, sumYY, sumXY := 0.0, 0.0, 0.0, 0.0, 0.0
+	for _, point := range points {
+		sumX = sumX + point.x
+		sumY = sumY + point.y
+		sumXX = sumXX + point.x*point.x
+		sumYY = sumYY + point.y*point.y
+		sumXY = sumXY + point.x*point.y
+	}
+
+	xVariance := n*sumXX - sumX*sumX
+	if n < 2 || xVariance == 0.0 {
+		return 0.0, 0.0, 0.0, false
+	}
+	covariance := n*sumXY - sumX*sumY
+	slope = covariance / xVariance
+	intercept = (sumY - slope*sumX) / n
+
+	//When every y value is the same the flat line fits them exactly
+	rSquared = 1.0
+	if yVariance := n*sumYY - sumY*sumY; yVariance != 0.0 {
+		rSquared = covariance * covariance / (xVariance * yVariance)
+	}
+
+	return slope, intercept, rSquared, true
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing a point's label next to its marker
+func DrawPointLabel(pdf *gofpdf.Fpdf, pointLabels PointLabels, label string, x, y, markerSize float64) {
+
+	if label == "" {
+		return
+	}
+
+	font := pointLabels.Font
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	label = TextForFont(pdf, font.Family, label)
+	labelWidth := pdf.GetStringWidth(label) + 2*pdf.GetCellMargin()
+	labelHeight := font.Size + font.LineSpacing
+	gap := 0.5*markerSize + pointLabels.Offset
+
+	labelXPosition, labelYPosition := x-0.5*labelWidth, y-0.5*labelHeight
+	switch pointLabels.Position {
+	case "above":
+		labelYPosition = y - gap - labelHeight
+	case "below":
+		labelYPosition = y + gap
+	case "left":
+		labelXPosition = x - gap - labelWidth
+	case "right":
+		labelXPosition = x + gap
+	}
+
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+	pdf.SetXY(labelXPosition, labelYPosition)
+	pdf.CellFormat(labelWidth, labelHeight, label, font.CellBorders.Style, 0, "CM", font.CellFill.Filled, 0, "")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitLinearTrend(t *testing.T) {
+
+	tests := []struct {
+		points                                 []scatterPoint
+		wantSlope, wantIntercept, wantRSquared float64
+	}{
+		{[]scatterPoint{{x: 0, y: 1}, {x: 1, y: 3}, {x: 2, y: 5}, {x: 3, y: 7}}, 2, 1, 1},
+		{[]scatterPoint{{x: 1, y: 1}, {x: 2, y: 3}, {x: 3, y: 2}}, 0.5, 1, 0.25},
+		{[]scatterPoint{{x: -2, y: 4}, {x: 2, y: 0}}, -1, 2, 1},
+		//A flat line fits points that all have the same y value exactly
+		{[]scatterPoint{{x: 1, y: 5}, {x: 2, y: 5}, {x: 4, y: 5}}, 0, 5, 1},
+	}
+
+	for _, test := range tests {
+		slope, intercept, rSquared, ok := FitLinearTrend(test.points)
+		if !ok {
+			t.Errorf("FitLinearTrend(%v) couldn't fit a line", test.points)
+			continue
+		}
+		if math.Abs(slope-test.wantSlope) > 1e-9 || math.Abs(intercept-test.wantIntercept) > 1e-9 || math.Abs(rSquared-test.wantRSquared) > 1e-9 {
+			t.Errorf("FitLinearTrend(%v) = %v, %v, %v, want %v, %v, %v", test.points, slope, intercept, rSquared, test.wantSlope, test.wantIntercept, test.wantRSquared)
+		}
+	}
+}
+
+func TestFitLinearTrendNeedsDifferentXValues(t *testing.T) {
+
+	for _, points := range [][]scatterPoint{
+		nil,
+		{{x: 1, y: 2}},
+		{{x: 3, y: 1}, {x: 3, y: 4}, {x: 3, y: 9}},
+	} {
+		if _, _, _, ok := FitLinearTrend(points); ok {
+			t.Errorf("FitLinearTrend(%v) fitted a line, want it to fail", points)
+		}
+	}
+}
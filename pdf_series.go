@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//A series of values on a chart with more than one, each one is a key in the data source's points. The label is what the legend shows,
+//the key when it isn't set. Series without a colour take the next colour from the chart's palette
+//
+//  "series": [
+//      {"key": "Cheddars", "colour": "#E49BB9"},
+//      {"key": "Bries", "label": "Brie"}
+//  ]
+//
+//A chart without any series shows its dataSeries in the series format's fill colour. On a combo chart each series' type is bar or
+//line, and its axis is primary, on the left, or secondary, on the right. Defaults for them are in the "series" element defaults
+type ChartSeries struct {
+	Key    string  `json: key`
+	Label  string  `json: label`
+	Colour *Colour `json: colour`
+	Type   string  `json: type`
+	Axis   string  `json: axis`
+}
+
+//A key to the series on a chart with more than one, in a row across the top right of the plot area. Each series has a swatch of its
+//colour and its label, written in the legend's font, with the font's cell fill behind the whole legend
+type Legend struct {
+	Show bool `json: show`
+	Font Font `json: font`
+}
+
+//////////////////////////////////////////////////////////////////////
+//The series a chart shows, with their labels and colours filled in
+func ChartSeriesForItem(chartItem PdfContentItem) (series []ChartSeries, err error) {
+
+	if len(chartItem.Series) == 0 {
+		if chartItem.DataSeries == "" {
+			return nil, fmt.Errorf("%s chart for %q needs a dataSeries or series", chartItem.ItemType, chartItem.DataSource)
+		}
+		fillColour := chartItem.ChartSettings.SeriesFormat.FillColour
+		return []ChartSeries{{Key: chartItem.DataSeries, Label: chartItem.DataSeries, Colour: &fillColour, Type: "bar", Axis: "primary"}}, nil
+	}
+
+	palette := chartItem.ChartSettings.Palette
+	for i, chartSeries := range chartItem.Series {
+		if chartSeries.Key == "" {
+			return nil, fmt.Errorf("%s chart series %d needs a key", chartItem.ItemType, i)
+		}
+		if chartSeries.Label == "" {
+			chartSeries.Label = chartSeries.Key
+		}
+		if chartSeries.Colour == nil {
+			if len(palette) == 0 {
+				return nil, fmt.Errorf("%s chart series %q has no colour and the chart has no palette", chartItem.ItemType, chartSeries.Key)
+			}
+			paletteColour := palette[i%len(palette)]
+			chartSeries.Colour = &paletteColour
+		}
+		series = append(series, chartSeries)
+	}
+
+	return series, nil
+}
+
+//Reading each series' values from the data source's points, one value for every point
+func chartSeriesValues(chartItem PdfContentItem, series []ChartSeries, dataPoints []interface{}) (values [][]float64, err error) {
+
+	values = make([][]float64, len(series))
+	for i, dataPoint := range dataPoints {
+		pointValues, _ := dataPoint.(map[string]interface{})
+		for seriesIndex, chartSeries := range series {
+			value, ok := pointValues[chartSeries.Key].(float64)
+			if !ok {
+				return nil, fmt.Errorf("point %d in %q doesn't have a number for %q", i, chartItem.DataSource, chartSeries.Key)
+			}
+			values[seriesIndex] = append(values[seriesIndex], value)
+		}
+	}
+
+	return values, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing the legend for a chart's series, against the top right corner of its plot area. Charts with only one series don't need one
+func DrawChartLegend(pdf *gofpdf.Fpdf, legend Legend, series []ChartSeries, plotArea ChartPlotArea) {
+
+	if !legend.Show || len(series) < 2 {
+		return
+	}
+
+	font := legend.Font
+	pdf.SetFont(font.Family, font.Style, font.Size)
+	lineHeight := font.Size + font.LineSpacing
+	swatchSize := 0.7 * font.Size
+	cellMargin := pdf.GetCellMargin()
+
+	//Working out how wide the legend is so that it can be lined up against the right of the plot area
+	var labels []string
+	legendWidth := cellMargin
+	for _, chartSeries := range series {
+		label := TextForFont(pdf, font.Family, chartSeries.Label)
+		labels = append(labels, label)
+		legendWidth = legendWidth + swatchSize + pdf.GetStringWidth(label) + 3*cellMargin
+	}
+	legendXPosition := plotArea.Right - legendWidth
+	legendYPosition := plotArea.Top
+
+	pdf.SetFillColor(font.CellFill.Colour.R, font.CellFill.Colour.G, font.CellFill.Colour.B)
+	pdf.SetDrawColor(font.CellBorders.Colour.R, font.CellBorders.Colour.G, font.CellBorders.Colour.B)
+	pdf.SetXY(legendXPosition, legendYPosition)
+	pdf.CellFormat(legendWidth, lineHeight, "", font.CellBorders.Style, 0, "", font.CellFill.Filled, 0, "")
+
+	entryXPosition := legendXPosition + cellMargin
+	pdf.SetTextColor(font.Colour.R, font.Colour.G, font.Colour.B)
+	for i, chartSeries := range series {
+		pdf.SetFillColor(chartSeries.Colour.R, chartSeries.Colour.G, chartSeries.Colour.B)
+		pdf.Rect(entryXPosition, legendYPosition+0.5*(lineHeight-swatchSize), swatchSize, swatchSize, "F")
+		labelWidth := pdf.GetStringWidth(labels[i]) + 2*cellMargin
+		pdf.SetXY(entryXPosition+swatchSize, legendYPosition)
+		pdf.CellFormat(labelWidth, lineHeight, labels[i], "", 0, "LM", false, 0, "")
+		entryXPosition = entryXPosition + swatchSize + labelWidth + cellMargin
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//The serve mode exposes the same InitialisePDF -> ProcessPDFContentsItems pipeline over HTTP:
+//
+//  POST /render   the recipe and data as JSON, {"recipe": {...}, "data": [...]}, or as multipart form parts named "recipe" and "data".
+//                 The pdf is sent back in the response, nothing is written to disk
+//  GET  /healthz  returns 200 while the server is up
+//  GET  /metrics  request, render and rejection counters in the Prometheus text format
+//
+//The timeout only stops the request waiting. A render that runs past it can't be stopped part way through, so it carries on in the
+//background and keeps its render slot until it finishes, and pdf_renders_in_flight still counts it. Progress and error messages go
+//to standard error, like they do when the pdf itself is written to standard output
+
+//Settings for the serve mode, read from its command line flags
+type ServerSettings struct {
+	Address         string
+	MaxRequestBytes int64
+	RenderTimeout   time.Duration
+	MaxConcurrent   int
+	ResourceDir     string
+}
+
+//The rendering service, renderSlots caps how many pdfs are rendered at once
+type renderServer struct {
+	settings    ServerSettings
+	renderSlots chan struct{}
+	metrics     *serverMetrics
+}
+
+//Counters reported on /metrics
+type serverMetrics struct {
+	mutex             sync.Mutex
+	requests          map[string]int64
+	rejected          map[string]int64
+	rendersInFlight   int64
+	renderCount       int64
+	renderSecondsSum  float64
+	renderedBytesSent int64
+}
+
+//////////////////////////////////////////////////////////////////////
+//Starting the HTTP rendering service, args are the command line arguments after "serve"
+func Serve(args []string) (err error) {
+
+	settings := ServerSettings{}
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.StringVar(&settings.Address, "addr", ":8080", "address to listen on")
+	flags.Int64Var(&settings.MaxRequestBytes, "max-request-bytes", 10<<20, "largest request body accepted, in bytes")
+	flags.DurationVar(&settings.RenderTimeout, "timeout", 30*time.Second, "how long a request waits for a render slot and its pdf, a render that times out still finishes in the background")
+	flags.IntVar(&settings.MaxConcurrent, "max-concurrent", runtime.NumCPU(), "how many pdfs can be rendered at the same time")
+	flags.StringVar(&settings.ResourceDir, "resource-dir", "", "directory that recipes can read theme and font files from, recipes can't read files when it's empty")
+	if err = flags.Parse(args); err != nil {
+		return err
+	}
+	if settings.MaxConcurrent < 1 {
+		return fmt.Errorf("max-concurrent must be at least 1, got %d", settings.MaxConcurrent)
+	}
+
+	//Recipes come from the network so they're only allowed to read files from the resource directory
+	ReadRecipeFile = resourceDirFileReader(settings.ResourceDir)
+
+	//Each item's progress message is logged while the pdfs are rendered, those go to the server's log rather than standard output
+	logOutput = os.Stderr
+
+	server := &renderServer{
+		settings:    settings,
+		renderSlots: make(chan struct{}, settings.MaxConcurrent),
+		metrics:     &serverMetrics{requests: map[string]int64{}, rejected: map[string]int64{}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/render", server.countRequests("/render", server.handleRender))
+	mux.HandleFunc("/healthz", server.countRequests("/healthz", server.handleHealthz))
+	mux.HandleFunc("/metrics", server.handleMetrics)
+
+	httpServer := &http.Server{
+		Addr:              settings.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       settings.RenderTimeout,
+		WriteTimeout:      2 * settings.RenderTimeout,
+	}
+
+	//Finishing the requests that are in progress when we're asked to stop
+	shutdownFinished := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 2*settings.RenderTimeout)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+		close(shutdownFinished)
+	}()
+
+	fmt.Fprintln(logOutput, "Serving pdf rendering on", settings.Address)
+	if err = httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdownFinished
+
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Rendering a pdf from the recipe and data in the request and sending it back
+func (server *renderServer) handleRender(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "render only accepts POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, server.settings.MaxRequestBytes)
+	recipeJSON, dataJSON, err := readRenderRequest(r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request is larger than the %d byte limit", server.settings.MaxRequestBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recipe, err := LoadRecipe(recipeJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var data Data
+	if len(dataJSON) > 0 {
+		if err = json.Unmarshal(dataJSON, &data); err != nil {
+			http.Error(w, fmt.Sprintf("reading data: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	//The timeout covers both waiting for a render slot and the render itself. It only stops this request waiting, a render that's
+	//already started can't be cancelled so it finishes in the background and holds its slot until then
+	ctx, cancel := context.WithTimeout(r.Context(), server.settings.RenderTimeout)
+	defer cancel()
+
+	select {
+	case server.renderSlots <- struct{}{}:
+	case <-ctx.Done():
+		server.metrics.reject("busy")
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many pdfs are being rendered, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	type renderResult struct {
+		pdf []byte
+		err error
+	}
+	//Buffered so the render can finish and free its slot even when nobody is waiting for it any more
+	rendered := make(chan renderResult, 1)
+
+	go func() {
+		renderStarted := time.Now()
+		server.metrics.renderStarted()
+		defer func() {
+			//Recipe data that doesn't match what an item expects can panic inside the renderers, that shouldn't take the server down
+			if recovered := recover(); recovered != nil {
+				rendered <- renderResult{err: fmt.Errorf("rendering failed: %v", recovered)}
+			}
+			server.metrics.renderFinished(time.Since(renderStarted))
+			<-server.renderSlots
+		}()
+
+		pdf, err := RenderPDF(recipe, data)
+		if err != nil {
+			rendered <- renderResult{err: err}
+			return
+		}
+		pdfBytes, err := PDFBytes(pdf)
+		rendered <- renderResult{pdf: pdfBytes, err: err}
+	}()
+
+	select {
+	case result := <-rendered:
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		pdfFileName := recipe.PdfSettings.PdfName
+		if pdfFileName == "" {
+			pdfFileName = "example_pdf"
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": pdfFileName + ".pdf"}))
+		w.Header().Set("Content-Length", fmt.Sprint(len(result.pdf)))
+		w.WriteHeader(http.StatusOK)
+		written, _ := w.Write(result.pdf)
+		server.metrics.bytesSent(written)
+
+	case <-ctx.Done():
+		//The render is still going, its result is dropped into the buffered channel when it finishes
+		server.metrics.reject("timeout")
+		http.Error(w, fmt.Sprintf("rendering took longer than %v", server.settings.RenderTimeout), http.StatusServiceUnavailable)
+	}
+}
+
+//////////////////////////////////////////////////////////////////////
+//Pulling the recipe and data out of either a JSON body or a multipart form. Multipart parts are read straight into memory, rather
+//than using ParseMultipartForm which spills large files to disk
+func readRenderRequest(r *http.Request) (recipeJSON []byte, dataJSON []byte, err error) {
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "multipart/form-data" {
+		multipartReader, err := r.MultipartReader()
+		if err != nil {
+			return nil, nil, err
+		}
+		for {
+			part, err := multipartReader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			switch part.FormName() {
+			case "recipe":
+				recipeJSON, err = ioutil.ReadAll(part)
+			case "data":
+				dataJSON, err = ioutil.ReadAll(part)
+			default:
+				_, err = io.Copy(ioutil.Discard, part)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		var renderRequest struct {
+			Recipe json.RawMessage `json: recipe`
+			Data   json.RawMessage `json: data`
+		}
+		if err = json.NewDecoder(r.Body).Decode(&renderRequest); err != nil {
+			return nil, nil, fmt.Errorf("reading request: %v", err)
+		}
+		recipeJSON, dataJSON = renderRequest.Recipe, renderRequest.Data
+	}
+
+	if len(recipeJSON) == 0 {
+		return nil, nil, fmt.Errorf("request has no recipe")
+	}
+
+	return recipeJSON, dataJSON, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Only letting recipes read files by relative paths inside the resource directory
+func resourceDirFileReader(resourceDir string) func(string) ([]byte, error) {
+	return func(location string) ([]byte, error) {
+		if resourceDir == "" {
+			return nil, fmt.Errorf("recipe refers to the file %q but the server has no resource directory", location)
+		}
+		cleanedLocation := filepath.Clean(filepath.FromSlash(location))
+		if filepath.IsAbs(cleanedLocation) || cleanedLocation == ".." || strings.HasPrefix(cleanedLocation, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("recipe file %q must be a relative path inside the resource directory", location)
+		}
+		return ioutil.ReadFile(filepath.Join(resourceDir, cleanedLocation))
+	}
+}
+
+func (server *renderServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+//////////////////////////////////////////////////////////////////////
+//Writing out the counters in the Prometheus text format
+func (server *renderServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+
+	metrics := server.metrics
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP pdf_http_requests_total HTTP requests by path and status code.")
+	fmt.Fprintln(w, "# TYPE pdf_http_requests_total counter")
+	for _, key := range sortedKeys(metrics.requests) {
+		fmt.Fprintf(w, "pdf_http_requests_total{%s} %d\n", key, metrics.requests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP pdf_render_rejected_total Renders that were turned away, because the server was busy or the render timed out.")
+	fmt.Fprintln(w, "# TYPE pdf_render_rejected_total counter")
+	for _, reason := range sortedKeys(metrics.rejected) {
+		fmt.Fprintf(w, "pdf_render_rejected_total{reason=%q} %d\n", reason, metrics.rejected[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP pdf_renders_in_flight Pdfs being rendered right now.")
+	fmt.Fprintln(w, "# TYPE pdf_renders_in_flight gauge")
+	fmt.Fprintf(w, "pdf_renders_in_flight %d\n", metrics.rendersInFlight)
+
+	fmt.Fprintln(w, "# HELP pdf_render_duration_seconds Time spent rendering pdfs.")
+	fmt.Fprintln(w, "# TYPE pdf_render_duration_seconds summary")
+	fmt.Fprintf(w, "pdf_render_duration_seconds_sum %g\n", metrics.renderSecondsSum)
+	fmt.Fprintf(w, "pdf_render_duration_seconds_count %d\n", metrics.renderCount)
+
+	fmt.Fprintln(w, "# HELP pdf_rendered_bytes_total Bytes of pdf sent back to clients.")
+	fmt.Fprintln(w, "# TYPE pdf_rendered_bytes_total counter")
+	fmt.Fprintf(w, "pdf_rendered_bytes_total %d\n", metrics.renderedBytesSent)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Wrapping a handler so the status code of each response is counted
+func (server *renderServer) countRequests(path string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		server.metrics.mutex.Lock()
+		server.metrics.requests[fmt.Sprintf("path=%q,code=\"%d\"", path, recorder.status)]++
+		server.metrics.mutex.Unlock()
+	}
+}
+
+//Remembering the status code that a handler writes
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (metrics *serverMetrics) reject(reason string) {
+	metrics.mutex.Lock()
+	metrics.rejected[reason]++
+	metrics.mutex.Unlock()
+}
+
+func (metrics *serverMetrics) renderStarted() {
+	metrics.mutex.Lock()
+	metrics.rendersInFlight++
+	metrics.mutex.Unlock()
+}
+
+func (metrics *serverMetrics) renderFinished(duration time.Duration) {
+	metrics.mutex.Lock()
+	metrics.rendersInFlight--
+	metrics.renderCount++
+	metrics.renderSecondsSum += duration.Seconds()
+	metrics.mutex.Unlock()
+}
+
+func (metrics *serverMetrics) bytesSent(written int) {
+	metrics.mutex.Lock()
+	metrics.renderedBytesSent += int64(written)
+	metrics.mutex.Unlock()
+}
+
+func sortedKeys(counters map[string]int64) (keys []string) {
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//Sparklines are small charts without axes, labels or titles that show the shape of a series of values in a small space. They're
+//drawn as a line or as mini bars, scaled so that the values fill the box inside the padding. Bars start from 0, so they show which
+//values are negative. The lowest, highest and last points can be picked out, as markers on a line or as bars in their colour
+//
+//  "itemType": "sparkline",
+//  "dataSource": "Number of cheeses sold per month", "dataSeries": "Bries",
+//  "sparklineSettings": {"type": "bar", "maxPoint": {"show": true}}
+//
+//A table column with the sparkline type draws one in each row's cell. Its values are the row's key, when it's a list of numbers, or
+//the points in another data source grouped by the row's key, when the column's sparkline has a dataSource
+//
+//  "columns": [
+//      {"key": "Region"},
+//      {"key": "Region", "header": "Trend", "type": "sparkline",
+//       "sparkline": {"dataSource": "Monthly sales", "groupKey": "Region", "valueKey": "Sales", "lastPoint": {"show": true}}}
+//  ]
+//
+//The points in a grouped data source are drawn in the order they're in, and the groups are matched on the row's value as it's written
+type SparklineSettings struct {
+	Type           string         `json: type`
+	Format         ShapeStyle     `json: format`
+	GapBetweenBars float64        `json: gapBetweenBars`
+	Padding        float64        `json: padding`
+	MarkerSize     float64        `json: markerSize`
+	MinPoint       SparklinePoint `json: minPoint`
+	MaxPoint       SparklinePoint `json: maxPoint`
+	LastPoint      SparklinePoint `json: lastPoint`
+	DataSource     string         `json: dataSource`
+	GroupKey       string         `json: groupKey`
+	ValueKey       string         `json: valueKey`
+}
+
+//A point on a sparkline that's picked out in its own colour
+type SparklinePoint struct {
+	Show   bool   `json: show`
+	Colour Colour `json: colour`
+}
+
+//The types a table column can be, text columns write their values and sparkline columns draw them
+var tableColumnTypes = map[string]bool{"": true, "text": true, "sparkline": true}
+
+//////////////////////////////////////////////////////////////////////
+//Checking a sparkline's settings
+func ValidateSparkline(settings SparklineSettings) (err error) {
+
+	if settings.Type != "line" && settings.Type != "bar" {
+		return fmt.Errorf("unsupported sparkline type %q, expected line or bar", settings.Type)
+	}
+	if settings.GapBetweenBars < 0.0 || settings.Padding < 0.0 || settings.MarkerSize < 0.0 {
+		return fmt.Errorf("sparkline gap between bars %v, padding %v and marker size %v can't be below 0", settings.GapBetweenBars, settings.Padding, settings.MarkerSize)
+	}
+
+	return err
+}
+
+//Checking a table column's type, and its sparkline when it has one
+func ValidateSparklineColumn(column TableColumn) (err error) {
+
+	if !tableColumnTypes[column.Type] {
+		return fmt.Errorf("column %q has unsupported type %q, expected text or sparkline", column.Key, column.Type)
+	}
+	if column.Type != "sparkline" {
+		return err
+	}
+	if err = ValidateSparkline(column.Sparkline); err != nil {
+		return fmt.Errorf("column %q: %v", column.Key, err)
+	}
+	if column.Sparkline.DataSource != "" && (column.Sparkline.GroupKey == "" || column.Sparkline.ValueKey == "") {
+		return fmt.Errorf("column %q sparkline reads from %q, so it needs a groupKey and a valueKey", column.Key, column.Sparkline.DataSource)
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Processing sparklines
+func ProcessSparklinePDFItem(pdf *gofpdf.Fpdf, sparklineItem PdfContentItem, pdfSettings PdfFields, data Data) (err error) {
+
+	if err = ValidateSparkline(sparklineItem.SparklineSettings); err != nil {
+		return err
+	}
+	if sparklineItem.DataSeries == "" {
+		return fmt.Errorf("sparkline for %q needs a dataSeries", sparklineItem.DataSource)
+	}
+
+	for _, dataset := range data {
+		if sparklineItem.DataSource == dataset.DataSource {
+			values, err := chartSeriesValues(sparklineItem, []ChartSeries{{Key: sparklineItem.DataSeries}}, dataset.DataPoints)
+			if err != nil {
+				return err
+			}
+			DrawSparkline(pdf, sparklineItem.SparklineSettings, values[0], sparklineItem.XPosition+pdfSettings.PdfSettings.PageLeftAndRightMargins, sparklineItem.YPosition+pdfSettings.PdfSettings.PageTopMargin, sparklineItem.Width, sparklineItem.Height)
+		}
+	}
+
+	return err
+}
+
+//Reading the values for a row's sparkline in a table column, from the row itself or from the column's grouped data source
+func SparklineValuesForRow(column TableColumn, row map[string]interface{}, data Data) (values []float64, err error) {
+
+	if column.Sparkline.DataSource == "" {
+		list, ok := row[column.Key].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("column %q needs a list of numbers in each row for its sparkline, got %v", column.Key, row[column.Key])
+		}
+		for i, listValue := range list {
+			value, ok := listValue.(float64)
+			if !ok {
+				return nil, fmt.Errorf("column %q sparkline value %d, %v, isn't a number", column.Key, i, listValue)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	group := FormatCellValue(row[column.Key])
+	for _, dataset := range data {
+		if column.Sparkline.DataSource == dataset.DataSource {
+			for i, dataPoint := range dataset.DataPoints {
+				pointValues, _ := dataPoint.(map[string]interface{})
+				if FormatCellValue(pointValues[column.Sparkline.GroupKey]) != group {
+					continue
+				}
+				value, ok := pointValues[column.Sparkline.ValueKey].(float64)
+				if !ok {
+					return nil, fmt.Errorf("point %d in %q doesn't have a number for %q", i, dataset.DataSource, column.Sparkline.ValueKey)
+				}
+				values = append(values, value)
+			}
+			return values, nil
+		}
+	}
+
+	return nil, fmt.Errorf("column %q sparkline data source %q isn't in the data", column.Key, column.Sparkline.DataSource)
+}
+
+//////////////////////////////////////////////////////////////////////
+//Drawing a sparkline in a box. The line width and draw colour are put back afterwards, so that it can be drawn in the middle of
+//a table without changing the cell borders after it
+func DrawSparkline(pdf *gofpdf.Fpdf, settings SparklineSettings, values []float64, x, y, width, height float64) {
+
+	if len(values) == 0 {
+		return
+	}
+
+	lineWidth := pdf.GetLineWidth()
+	drawR, drawG, drawB := pdf.GetDrawColor()
+	defer func() {
+		pdf.SetLineWidth(lineWidth)
+		pdf.SetDrawColor(drawR, drawG, drawB)
+	}()
+
+	left, right := x+settings.Padding, x+width-settings.Padding
+	top, bottom := y+settings.Padding, y+height-settings.Padding
+
+	//Scaling the values to fill the box, bars always include 0 so that they have somewhere to start from
+	lowest, highest := valueRange(values)
+	scaleMin, scaleMax := lowest, highest
+	if settings.Type == "bar" {
+		scaleMin, scaleMax = math.Min(scaleMin, 0.0), math.Max(scaleMax, 0.0)
+	}
+	if scaleMax == scaleMin {
+		scaleMin, scaleMax = scaleMin-1.0, scaleMax+1.0
+	}
+	yPosition := func(value float64) float64 {
+		return bottom - (value-scaleMin)/(scaleMax-scaleMin)*(bottom-top)
+	}
+
+	//The points that are picked out, the last point wins over the highest and the highest over the lowest when they're the same point
+	highlights := map[int]Colour{}
+	for _, highlight := range []struct {
+		point SparklinePoint
+		index int
+	}{
+		{settings.MinPoint, indexOfValue(values, lowest)},
+		{settings.MaxPoint, indexOfValue(values, highest)},
+		{settings.LastPoint, len(values) - 1},
+	} {
+		if highlight.point.Show {
+			highlights[highlight.index] = highlight.point.Colour
+		}
+	}
+
+	format := settings.Format
+	if settings.Type == "bar" {
+		slotWidth := (right - left) / float64(len(values))
+		barWidth := math.Max(slotWidth-settings.GapBetweenBars, 0.0)
+		zeroYPosition := yPosition(0.0)
+		pdf.SetLineWidth(format.LineWidth)
+		pdf.SetDrawColor(format.BorderColour.R, format.BorderColour.G, format.BorderColour.B)
+		for i, value := range values {
+			colour := format.FillColour
+			if highlightColour, ok := highlights[i]; ok {
+				colour = highlightColour
+			}
+			barXPosition := left + float64(i)*slotWidth + 0.5*(slotWidth-barWidth)
+			pdf.SetFillColor(colour.R, colour.G, colour.B)
+			DrawWithAlpha(pdf, colour, func() {
+				pdf.Rect(barXPosition, zeroYPosition, barWidth, yPosition(value)-zeroYPosition, format.Style)
+			})
+		}
+		return
+	}
+
+	//A line with a single value is a point in the middle of the box
+	xPosition := func(i int) float64 {
+		if len(values) == 1 {
+			return 0.5 * (left + right)
+		}
+		return left + float64(i)*(right-left)/float64(len(values)-1)
+	}
+	pdf.SetLineWidth(format.LineWidth)
+	pdf.SetDrawColor(format.LineColour.R, format.LineColour.G, format.LineColour.B)
+	DrawWithAlpha(pdf, format.LineColour, func() {
+		for i := 1; i < len(values); i++ {
+			pdf.Line(xPosition(i-1), yPosition(values[i-1]), xPosition(i), yPosition(values[i]))
+		}
+	})
+	for i := range values {
+		colour, ok := highlights[i]
+		if !ok {
+			continue
+		}
+		DrawMarker(pdf, "circle", ShapeStyle{Style: "F", FillColour: colour}, xPosition(i), yPosition(values[i]), settings.MarkerSize)
+	}
+}
+
+//The position of the first value that's the same as the one given
+func indexOfValue(values []float64, value float64) int {
+	for i, candidate := range values {
+		if candidate == value {
+			return i
+		}
+	}
+	return 0
+}
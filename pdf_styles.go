@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+//Named styles are resolved on the raw JSON before it's mapped onto the PdfFields struct. That way an inline setting only overrides
+//a named style when it's actually written in the recipe, rather than whenever the struct field happens to be its zero value.
+//
+//The layering for every item is: the defaults in recipeDefaultsJSON -> the theme -> named styles -> the inline recipe settings
+//
+//  "theme": "pdf_theme.json",
+//  "styles": {
+//      "chartText": {"size": 5.5, "style": "B"}
+//  },
+//  "itemDefaults": {
+//      "verticalBar": {"chartSettings": {"chartTextFont": {"styleName": "chartText"}}}
+//  }
+//
+//Any object in the recipe can carry a "styleName" - a Font, ShapeStyle or ChartSettings - and the named style is merged underneath it.
+//Setting a value to null is the same as leaving it out, so it falls back to the layer underneath
+//
+//Arrays replace the layers underneath them as a whole, so the objects in them can't pick up defaults from itemDefaults. Instead
+//"elementDefaults" has defaults for the objects in arrays, keyed by the array's name, and they're merged underneath every object
+//in an array with that name. It's layered the same way as itemDefaults, from the built-in defaults up to the recipe
+//
+//  "elementDefaults": {
+//      "annotations": {"font": {"styleName": "chartText"}}
+//  }
+
+//The name of the property that references a named style
+const styleNameKey = "styleName"
+
+//Reads the files that a recipe refers to, like its theme and fonts. The serve mode swaps this out so that recipes sent to it can only
+//read files from its resource directory
+var ReadRecipeFile = ioutil.ReadFile
+
+//////////////////////////////////////////////////////////////////////
+//Reading the recipe and resolving its defaults, theme, item defaults and named styles, then mapping it onto the PdfFields struct
+func LoadRecipe(recipeJSON []byte) (recipe PdfFields, err error) {
+
+	var rawRecipe map[string]interface{}
+	if err = json.Unmarshal(recipeJSON, &rawRecipe); err != nil {
+		return recipe, fmt.Errorf("reading recipe: %v", err)
+	}
+
+	//The bottom layer, already resolved since its named styles are separate from the recipe's
+	pdfSettings, builtInItemDefaults, elementDefaults, err := loadRecipeDefaults()
+	if err != nil {
+		return recipe, err
+	}
+
+	styles := map[string]interface{}{}
+	itemDefaults := map[string]interface{}{}
+	var elementDefaultLayers []map[string]interface{}
+
+	//The theme file has the same "pdfSettings", "styles", "itemDefaults" and "elementDefaults" sections as the recipe, anything in the
+	//recipe is layered on top of it
+	if themeLocation, ok := rawRecipe["theme"].(string); ok && themeLocation != "" {
+		themeJSON, err := ReadRecipeFile(themeLocation)
+		if err != nil {
+			return recipe, fmt.Errorf("reading theme: %v", err)
+		}
+		var theme map[string]interface{}
+		if err = json.Unmarshal(themeJSON, &theme); err != nil {
+			return recipe, fmt.Errorf("reading theme %q: %v", themeLocation, err)
+		}
+		pdfSettings = MergeJSONObjects(pdfSettings, jsonObject(theme["pdfSettings"]))
+		styles = MergeJSONObjects(styles, jsonObject(theme["styles"]))
+		itemDefaults = MergeJSONObjects(itemDefaults, jsonObject(theme["itemDefaults"]))
+		elementDefaultLayers = append(elementDefaultLayers, jsonObject(theme["elementDefaults"]))
+	}
+	rawRecipe["pdfSettings"] = MergeJSONObjects(pdfSettings, jsonObject(rawRecipe["pdfSettings"]))
+	styles = MergeJSONObjects(styles, jsonObject(rawRecipe["styles"]))
+	itemDefaults = MergeJSONObjects(itemDefaults, jsonObject(rawRecipe["itemDefaults"]))
+	elementDefaultLayers = append(elementDefaultLayers, jsonObject(rawRecipe["elementDefaults"]))
+
+	//The element defaults are resolved once all the styles are known, each layer separately like the items' layers
+	for _, layer := range elementDefaultLayers {
+		resolvedLayer, err := resolveNamedStyles(layer, styles, nil)
+		if err != nil {
+			return recipe, fmt.Errorf("element defaults: %v", err)
+		}
+		elementDefaults = MergeJSONObjects(elementDefaults, resolvedLayer.(map[string]interface{}))
+	}
+
+	contents, _ := rawRecipe["pdfContents"].([]interface{})
+	for i, item := range contents {
+		itemObject, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemType, _ := itemObject["itemType"].(string)
+
+		//Each layer has its named styles resolved before it's merged, so a styleName in one layer doesn't replace the layers underneath it
+		resolvedItem := jsonObject(builtInItemDefaults[itemType])
+		for _, layer := range []interface{}{jsonObject(itemDefaults[itemType]), itemObject} {
+			resolvedLayer, err := resolveNamedStyles(layer, styles, nil)
+			if err != nil {
+				return recipe, fmt.Errorf("%s item %d: %v", itemType, i, err)
+			}
+			resolvedItem = MergeJSONObjects(resolvedItem, resolvedLayer.(map[string]interface{}))
+		}
+		contents[i] = applyElementDefaults(resolvedItem, elementDefaults)
+	}
+
+	resolvedJSON, err := json.Marshal(rawRecipe)
+	if err != nil {
+		return recipe, err
+	}
+	err = json.Unmarshal(resolvedJSON, &recipe)
+
+	return recipe, err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Walking a JSON value and replacing every "styleName" reference with the named style, with the object's own settings merged on top
+func resolveNamedStyles(value interface{}, styles map[string]interface{}, stylesBeingResolved []string) (resolved interface{}, err error) {
+
+	switch typedValue := value.(type) {
+
+	case map[string]interface{}:
+		resolvedObject := map[string]interface{}{}
+		for key, child := range typedValue {
+			if key == styleNameKey {
+				continue
+			}
+			if resolvedObject[key], err = resolveNamedStyles(child, styles, stylesBeingResolved); err != nil {
+				return nil, err
+			}
+		}
+
+		styleName, ok := typedValue[styleNameKey].(string)
+		if !ok {
+			return resolvedObject, nil
+		}
+
+		//A style that references itself, directly or through another style, would never finish resolving
+		for _, name := range stylesBeingResolved {
+			if name == styleName {
+				return nil, fmt.Errorf("style %q references itself (%s -> %s)", styleName, strings.Join(stylesBeingResolved, " -> "), styleName)
+			}
+		}
+		namedStyle, ok := styles[styleName].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("style %q isn't defined in the recipe or theme styles", styleName)
+		}
+		resolvedStyle, err := resolveNamedStyles(namedStyle, styles, append(stylesBeingResolved, styleName))
+		if err != nil {
+			return nil, err
+		}
+
+		return MergeJSONObjects(resolvedStyle.(map[string]interface{}), resolvedObject), nil
+
+	case []interface{}:
+		resolvedArray := make([]interface{}, len(typedValue))
+		for i, child := range typedValue {
+			if resolvedArray[i], err = resolveNamedStyles(child, styles, stylesBeingResolved); err != nil {
+				return nil, err
+			}
+		}
+		return resolvedArray, nil
+	}
+
+	return value, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//Walking a JSON value and merging the element defaults underneath every object in an array that has element defaults
+func applyElementDefaults(value interface{}, elementDefaults map[string]interface{}) interface{} {
+
+	switch typedValue := value.(type) {
+
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			defaults, hasDefaults := elementDefaults[key].(map[string]interface{})
+			array, isArray := child.([]interface{})
+			if hasDefaults && isArray {
+				for i, element := range array {
+					if elementObject, ok := element.(map[string]interface{}); ok {
+						array[i] = MergeJSONObjects(defaults, elementObject)
+					}
+				}
+			}
+			typedValue[key] = applyElementDefaults(child, elementDefaults)
+		}
+
+	case []interface{}:
+		for i, child := range typedValue {
+			typedValue[i] = applyElementDefaults(child, elementDefaults)
+		}
+	}
+
+	return value
+}
+
+//////////////////////////////////////////////////////////////////////
+//Deep merging two JSON objects into a new one. Objects are merged key by key, anything else in the override replaces the base value unless it's null
+func MergeJSONObjects(base map[string]interface{}, override map[string]interface{}) (merged map[string]interface{}) {
+
+	merged = map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = copyJSONValue(value)
+	}
+	for key, value := range override {
+		//A null is treated as unset, so the base value is kept
+		if value == nil {
+			continue
+		}
+		baseObject, baseIsObject := merged[key].(map[string]interface{})
+		overrideObject, overrideIsObject := value.(map[string]interface{})
+		if baseIsObject && overrideIsObject {
+			merged[key] = MergeJSONObjects(baseObject, overrideObject)
+		} else {
+			merged[key] = copyJSONValue(value)
+		}
+	}
+
+	return merged
+}
+
+//Copying objects and arrays so that a named style used by several items isn't shared between them
+func copyJSONValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return MergeJSONObjects(typedValue, nil)
+	case []interface{}:
+		copied := make([]interface{}, len(typedValue))
+		for i, child := range typedValue {
+			copied[i] = copyJSONValue(child)
+		}
+		return copied
+	}
+	return value
+}
+
+//Treating a missing or non-object JSON value as an empty object
+func jsonObject(value interface{}) map[string]interface{} {
+	if object, ok := value.(map[string]interface{}); ok {
+		return object
+	}
+	return map[string]interface{}{}
+}
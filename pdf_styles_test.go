@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//Reading a JSON object for a test, so the expected values can be written the way they'd be in a recipe
+func testJSONObject(t *testing.T, objectJSON string) map[string]interface{} {
+	t.Helper()
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(objectJSON), &object); err != nil {
+		t.Fatalf("reading test JSON %s: %v", objectJSON, err)
+	}
+	return object
+}
+
+func TestMergeJSONObjects(t *testing.T) {
+
+	base := testJSONObject(t, `{"font": {"family": "Helvetica", "size": 10, "colour": {"R": 1, "G": 2, "B": 3}}, "columns": [{"key": "a"}], "width": 100}`)
+	override := testJSONObject(t, `{"font": {"size": 12, "colour": null}, "columns": [{"key": "b"}], "height": 50}`)
+
+	merged := MergeJSONObjects(base, override)
+	want := testJSONObject(t, `{"font": {"family": "Helvetica", "size": 12, "colour": {"R": 1, "G": 2, "B": 3}}, "columns": [{"key": "b"}], "width": 100, "height": 50}`)
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergeJSONObjects = %v, want %v", merged, want)
+	}
+
+	//The merged object is a copy, changing it doesn't change the base
+	merged["font"].(map[string]interface{})["family"] = "Courier"
+	if base["font"].(map[string]interface{})["family"] != "Helvetica" {
+		t.Errorf("changing the merged object changed the base")
+	}
+}
+
+func TestResolveNamedStyles(t *testing.T) {
+
+	styles := testJSONObject(t, `{
+		"body": {"family": "Helvetica", "size": 10},
+		"emphasis": {"styleName": "body", "style": "B"}
+	}`)
+	item := testJSONObject(t, `{"font": {"styleName": "emphasis", "size": 14}, "series": [{"font": {"styleName": "body"}}]}`)
+
+	resolved, err := resolveNamedStyles(item, styles, nil)
+	if err != nil {
+		t.Fatalf("resolveNamedStyles returned an error: %v", err)
+	}
+	want := testJSONObject(t, `{"font": {"family": "Helvetica", "size": 14, "style": "B"}, "series": [{"font": {"family": "Helvetica", "size": 10}}]}`)
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolveNamedStyles = %v, want %v", resolved, want)
+	}
+}
+
+func TestResolveNamedStylesErrors(t *testing.T) {
+
+	tests := []struct {
+		styles    string
+		item      string
+		wantError string
+	}{
+		{`{"a": {"styleName": "a"}}`, `{"font": {"styleName": "a"}}`, `style "a" references itself (a -> a)`},
+		{`{"a": {"styleName": "b"}, "b": {"styleName": "c"}, "c": {"styleName": "a"}}`, `{"font": {"styleName": "a"}}`, `style "a" references itself (a -> b -> c -> a)`},
+		{`{}`, `{"font": {"styleName": "missing"}}`, `style "missing" isn't defined`},
+	}
+
+	for _, test := range tests {
+		_, err := resolveNamedStyles(testJSONObject(t, test.item), testJSONObject(t, test.styles), nil)
+		if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("resolveNamedStyles with styles %s returned %v, want an error containing %q", test.styles, err, test.wantError)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+//Settings for a table as a whole. When stripedRows is filled, every second data row is filled in its colour. groupBy is a key in the
+//data to group the rows by, and subtotals and grandTotal add rows with the columns' aggregates, see pdf_tabletotals.go
+type TableSettings struct {
+	StripedRows   CellFill `json: stripedRows`
+	GroupBy       string   `json: groupBy`
+	Subtotals     bool     `json: subtotals`
+	GrandTotal    bool     `json: grandTotal`
+	SubtotalLabel string   `json: subtotalLabel`
+	TotalLabel    string   `json: totalLabel`
+}
+
+//A conditional format on a column. The condition compares each cell's value with the rule's value:
+//
+//  "<", "<=", ">", ">=", "==", "!="   numbers compare as numbers, text and true or false can only be compared with == and !=
+//  "top", "bottom"                    the value is how many of the column's highest or lowest numbers match, ties included
+//
+//A matching cell gets whichever of the text colour, font style and fill colour the rule sets. Rules are applied in order, so a later
+//rule wins over an earlier one
+type CellRule struct {
+	Condition  string      `json: condition`
+	Value      interface{} `json: value`
+	TextColour *Colour     `json: textColour`
+	FontStyle  *string     `json: fontStyle`
+	FillColour *Colour     `json: fillColour`
+}
+
+//Filling a column's cells on a scale between two colours by their value. The scale runs from the column's lowest to highest number,
+//unless min and max are set. Cells that aren't numbers are left as they are
+type HeatMap struct {
+	LowColour  Colour   `json: lowColour`
+	HighColour Colour   `json: highColour`
+	Min        *float64 `json: min`
+	Max        *float64 `json: max`
+}
+
+//How a single cell is drawn once the stripes, heat map and rules have been applied
+type TableCellStyle struct {
+	TextColour Colour
+	FontStyle  string
+	Filled     bool
+	FillColour Colour
+}
+
+//The numbers in a column, lowest first, for the heat map scale and top and bottom rules
+type tableColumnNumbers []float64
+
+//////////////////////////////////////////////////////////////////////
+//Checking the rules and heat maps on a table's columns before drawing anything, rule font styles are checked with the other fonts
+func ValidateTableColumnStyles(columns []TableColumn) (err error) {
+
+	for _, column := range columns {
+		for _, rule := range column.Rules {
+			switch rule.Condition {
+			case "<", "<=", ">", ">=":
+				if _, ok := rule.Value.(float64); !ok {
+					return fmt.Errorf("column %q rule %q needs a number to compare with, got %v", column.Key, rule.Condition, rule.Value)
+				}
+			case "==", "!=":
+				switch rule.Value.(type) {
+				case float64, string, bool:
+				default:
+					return fmt.Errorf("column %q rule %q needs a number, text or true or false to compare with, got %v", column.Key, rule.Condition, rule.Value)
+				}
+			case "top", "bottom":
+				count, ok := rule.Value.(float64)
+				if !ok || count < 1 || count != float64(int(count)) {
+					return fmt.Errorf("column %q rule %q needs a whole number of cells from 1 upwards, got %v", column.Key, rule.Condition, rule.Value)
+				}
+			default:
+				return fmt.Errorf("column %q has unsupported rule condition %q, expected <, <=, >, >=, ==, !=, top or bottom", column.Key, rule.Condition)
+			}
+		}
+		if column.HeatMap != nil && column.HeatMap.Min != nil && column.HeatMap.Max != nil && *column.HeatMap.Min >= *column.HeatMap.Max {
+			return fmt.Errorf("column %q heat map min %v should be below its max %v", column.Key, *column.HeatMap.Min, *column.HeatMap.Max)
+		}
+	}
+
+	return err
+}
+
+//Collecting the numbers in each column
+func TableColumnNumbers(columns []TableColumn, dataPoints []interface{}) (columnNumbers []tableColumnNumbers) {
+
+	for _, column := range columns {
+		var numbers tableColumnNumbers
+		for _, point := range dataPoints {
+			row, _ := point.(map[string]interface{})
+			if value, ok := row[column.Key].(float64); ok {
+				numbers = append(numbers, value)
+			}
+		}
+		sort.Float64s(numbers)
+		columnNumbers = append(columnNumbers, numbers)
+	}
+
+	return columnNumbers
+}
+
+//////////////////////////////////////////////////////////////////////
+//Working out how a cell is drawn. It starts from the table's font, then the row's stripe, the link colour if the cell is a link,
+//the column's heat map and finally the column's rules
+func CellStyleForTable(font Font, settings TableSettings, column TableColumn, numbers tableColumnNumbers, rowIndex int, value interface{}, linked bool) (cellStyle TableCellStyle) {
+
+	cellStyle = TableCellStyle{TextColour: font.Colour, FontStyle: font.Style, Filled: font.CellFill.Filled, FillColour: font.CellFill.Colour}
+
+	if settings.StripedRows.Filled && rowIndex%2 == 1 {
+		cellStyle.Filled = true
+		cellStyle.FillColour = settings.StripedRows.Colour
+	}
+
+	if linked {
+		cellStyle.TextColour = font.LinkColour
+	}
+
+	number, isNumber := value.(float64)
+
+	if column.HeatMap != nil && isNumber && len(numbers) > 0 {
+		scaleMin, scaleMax := numbers[0], numbers[len(numbers)-1]
+		if column.HeatMap.Min != nil {
+			scaleMin = *column.HeatMap.Min
+		}
+		if column.HeatMap.Max != nil {
+			scaleMax = *column.HeatMap.Max
+		}
+		position := 0.5
+		if scaleMax > scaleMin {
+			position = (number - scaleMin) / (scaleMax - scaleMin)
+		}
+		cellStyle.Filled = true
+		cellStyle.FillColour = BlendColours(column.HeatMap.LowColour, column.HeatMap.HighColour, position)
+	}
+
+	for _, rule := range column.Rules {
+		if !cellMatchesRule(rule, value, numbers) {
+			continue
+		}
+		if rule.TextColour != nil {
+			cellStyle.TextColour = *rule.TextColour
+		}
+		if rule.FontStyle != nil {
+			cellStyle.FontStyle = *rule.FontStyle
+		}
+		if rule.FillColour != nil {
+			cellStyle.Filled = true
+			cellStyle.FillColour = *rule.FillColour
+		}
+	}
+
+	return cellStyle
+}
+
+//Checking a cell's value against a rule, rules are already validated by ValidateTableColumnStyles
+func cellMatchesRule(rule CellRule, value interface{}, numbers tableColumnNumbers) bool {
+
+	number, isNumber := value.(float64)
+	ruleNumber, ruleIsNumber := rule.Value.(float64)
+
+	//Only single values are compared, cells holding lists or objects, like a sparkline column's, never equal the rule's value
+	if rule.Condition == "==" || rule.Condition == "!=" {
+		equal := false
+		switch value.(type) {
+		case float64, string, bool:
+			equal = value == rule.Value
+		}
+		return equal == (rule.Condition == "==")
+	}
+
+	if !isNumber || !ruleIsNumber {
+		return false
+	}
+
+	switch rule.Condition {
+	case "<":
+		return number < ruleNumber
+	case "<=":
+		return number <= ruleNumber
+	case ">":
+		return number > ruleNumber
+	case ">=":
+		return number >= ruleNumber
+	case "top":
+		count := int(ruleNumber)
+		if count >= len(numbers) {
+			return true
+		}
+		return number >= numbers[len(numbers)-count]
+	case "bottom":
+		count := int(ruleNumber)
+		if count >= len(numbers) {
+			return true
+		}
+		return number <= numbers[count-1]
+	}
+
+	return false
+}
+
+//A colour part way between two colours, position 0 is the first colour and 1 is the second
+func BlendColours(fromColour Colour, toColour Colour, position float64) Colour {
+
+	if position < 0.0 {
+		position = 0.0
+	}
+	if position > 1.0 {
+		position = 1.0
+	}
+	//Rounding to the nearest whole number, converting to an int on its own rounds towards 0 and leaves a channel that's going down
+	//one short of the colour it's going to
+	blend := func(from, to int) int {
+		return from + int(math.Floor(float64(to-from)*position+0.5))
+	}
+
+	return Colour{
+		R: blend(fromColour.R, toColour.R),
+		G: blend(fromColour.G, toColour.G),
+		B: blend(fromColour.B, toColour.B),
+		A: fromColour.A + (toColour.A-fromColour.A)*position,
+	}
+}
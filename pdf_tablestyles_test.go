@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestBlendColours(t *testing.T) {
+
+	white := Colour{R: 255, G: 255, B: 255, A: 1}
+	navy := Colour{R: 0, G: 0, B: 128, A: 0.5}
+
+	tests := []struct {
+		position float64
+		want     Colour
+	}{
+		{0, white},
+		{1, navy},
+		{0.5, Colour{R: 128, G: 128, B: 192, A: 0.75}},
+		//Positions past the ends are kept to the two colours
+		{-1, white},
+		{2, navy},
+	}
+
+	for _, test := range tests {
+		if colour := BlendColours(white, navy, test.position); colour != test.want {
+			t.Errorf("BlendColours(%+v, %+v, %v) = %+v, want %+v", white, navy, test.position, colour, test.want)
+		}
+	}
+}
+
+func TestValidateTableColumnStylesRuleValues(t *testing.T) {
+
+	for _, value := range []interface{}{nil, []interface{}{1.0, 2.0}, map[string]interface{}{"a": 1.0}} {
+		columns := []TableColumn{{Key: "Trend", Rules: []CellRule{{Condition: "==", Value: value}}}}
+		if err := ValidateTableColumnStyles(columns); err == nil {
+			t.Errorf("ValidateTableColumnStyles with an == rule on %v didn't return an error", value)
+		}
+	}
+	for _, value := range []interface{}{3.0, "Brie", true} {
+		columns := []TableColumn{{Key: "Trend", Rules: []CellRule{{Condition: "!=", Value: value}}}}
+		if err := ValidateTableColumnStyles(columns); err != nil {
+			t.Errorf("ValidateTableColumnStyles with a != rule on %v returned an error: %v", value, err)
+		}
+	}
+}
+
+func TestCellMatchesRule(t *testing.T) {
+
+	numbers := tableColumnNumbers{1, 2, 3, 4, 5}
+	tests := []struct {
+		rule  CellRule
+		value interface{}
+		want  bool
+	}{
+		{CellRule{Condition: "==", Value: "Brie"}, "Brie", true},
+		{CellRule{Condition: "==", Value: "Brie"}, "Feta", false},
+		{CellRule{Condition: "!=", Value: 3.0}, 3.0, false},
+		{CellRule{Condition: "==", Value: 3.0}, "3", false},
+		//Cells holding lists, like a sparkline column's, never equal a rule's value
+		{CellRule{Condition: "==", Value: 1.0}, []interface{}{1.0}, false},
+		{CellRule{Condition: "!=", Value: 1.0}, []interface{}{1.0}, true},
+		{CellRule{Condition: ">=", Value: 4.0}, 4.0, true},
+		{CellRule{Condition: "<", Value: 4.0}, "3", false},
+		{CellRule{Condition: "top", Value: 2.0}, 4.0, true},
+		{CellRule{Condition: "top", Value: 2.0}, 3.0, false},
+		{CellRule{Condition: "bottom", Value: 2.0}, 2.0, true},
+	}
+
+	for _, test := range tests {
+		if got := cellMatchesRule(test.rule, test.value, numbers); got != test.want {
+			t.Errorf("cellMatchesRule(%+v, %v) = %v, want %v", test.rule, test.value, got, test.want)
+		}
+	}
+}
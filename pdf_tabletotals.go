@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//The kinds of row a table can have. Data rows come from the data source, the rest are added by the table's settings
+const (
+	dataTableRow        = "data"
+	groupHeaderTableRow = "groupHeader"
+	subtotalTableRow    = "subtotal"
+	totalTableRow       = "total"
+)
+
+//The aggregates a column can have in subtotal and total rows
+var tableColumnAggregates = map[string]bool{"": true, "sum": true, "average": true, "count": true, "min": true, "max": true}
+
+//A row to draw in a table. Data rows have the row from the data and their position among the data rows, for striping. Group headers
+//have the group's value as their label, and subtotal and total rows have each column's aggregate in their values
+type TableRow struct {
+	Kind      string
+	Values    map[string]interface{}
+	DataIndex int
+	Label     string
+}
+
+//////////////////////////////////////////////////////////////////////
+//Checking the columns' aggregates and the table's grouping settings
+func ValidateTableTotals(columns []TableColumn, settings TableSettings) (err error) {
+
+	for _, column := range columns {
+		if !tableColumnAggregates[column.Aggregate] {
+			return fmt.Errorf("column %q has unsupported aggregate %q, expected sum, average, count, min or max", column.Key, column.Aggregate)
+		}
+	}
+	if settings.Subtotals && settings.GroupBy == "" {
+		return fmt.Errorf("table has subtotals but no groupBy key to group the rows by")
+	}
+
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//Listing the rows of a table in the order they're drawn. When the table is grouped, the groups are in the order their first row
+//appears in the data and each starts with a header row, followed by its rows and its subtotal if the table has subtotals.
+//The grand total comes last
+func TableRowsForItem(columns []TableColumn, settings TableSettings, dataPoints []interface{}) (tableRows []TableRow) {
+
+	var dataRows []map[string]interface{}
+	for _, point := range dataPoints {
+		row, _ := point.(map[string]interface{})
+		dataRows = append(dataRows, row)
+	}
+
+	dataIndex := 0
+	addDataRows := func(rows []map[string]interface{}) {
+		for _, row := range rows {
+			tableRows = append(tableRows, TableRow{Kind: dataTableRow, Values: row, DataIndex: dataIndex})
+			dataIndex++
+		}
+	}
+
+	if settings.GroupBy == "" {
+		addDataRows(dataRows)
+	} else {
+		var groupOrder []string
+		groups := map[string][]map[string]interface{}{}
+		for _, row := range dataRows {
+			groupName := FormatCellValue(row[settings.GroupBy])
+			if _, ok := groups[groupName]; !ok {
+				groupOrder = append(groupOrder, groupName)
+			}
+			groups[groupName] = append(groups[groupName], row)
+		}
+		for _, groupName := range groupOrder {
+			tableRows = append(tableRows, TableRow{Kind: groupHeaderTableRow, Label: groupName})
+			addDataRows(groups[groupName])
+			if settings.Subtotals {
+				tableRows = append(tableRows, TableRow{Kind: subtotalTableRow, Label: settings.SubtotalLabel, Values: AggregateTableRows(columns, groups[groupName])})
+			}
+		}
+	}
+
+	if settings.GrandTotal {
+		tableRows = append(tableRows, TableRow{Kind: totalTableRow, Label: settings.TotalLabel, Values: AggregateTableRows(columns, dataRows)})
+	}
+
+	return tableRows
+}
+
+//Working out each column's aggregate over some rows. Sum, average, min and max only look at numbers, count counts every cell that
+//has a value. Columns without an aggregate, and averages, mins and maxes without any numbers, are left out
+func AggregateTableRows(columns []TableColumn, rows []map[string]interface{}) (aggregates map[string]interface{}) {
+
+	aggregates = map[string]interface{}{}
+	for _, column := range columns {
+
+		if column.Aggregate == "" {
+			continue
+		}
+
+		sum, count, numberCount := 0.0, 0.0, 0.0
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, row := range rows {
+			value := row[column.Key]
+			if value == nil {
+				continue
+			}
+			count++
+			if number, ok := value.(float64); ok {
+				sum = sum + number
+				numberCount++
+				min = math.Min(min, number)
+				max = math.Max(max, number)
+			}
+		}
+
+		switch column.Aggregate {
+		case "sum":
+			aggregates[column.Key] = sum
+		case "count":
+			aggregates[column.Key] = count
+		case "average":
+			if numberCount > 0 {
+				aggregates[column.Key] = sum / numberCount
+			}
+		case "min":
+			if numberCount > 0 {
+				aggregates[column.Key] = min
+			}
+		case "max":
+			if numberCount > 0 {
+				aggregates[column.Key] = max
+			}
+		}
+	}
+
+	return aggregates
+}
+
+//The text for a cell in a subtotal or total row. The row's label goes in the first column, unless that column has an aggregate
+func TotalRowCellText(tableRow TableRow, columns []TableColumn, columnIndex int) string {
+	column := columns[columnIndex]
+	if columnIndex == 0 && column.Aggregate == "" {
+		return tableRow.Label
+	}
+	return FormatValue(tableRow.Values[column.Key], column.Format)
+}
+
+//Setting the font, colours and fill of a header, footer or group header row
+func setTableRowFont(pdf *gofpdf.Fpdf, rowFont HeaderFont) {
+	pdf.SetFont(rowFont.Family, rowFont.Style, rowFont.Size)
+	pdf.SetTextColor(rowFont.Colour.R, rowFont.Colour.G, rowFont.Colour.B)
+	pdf.SetFillColor(rowFont.CellFill.Colour.R, rowFont.CellFill.Colour.G, rowFont.CellFill.Colour.B)
+	pdf.SetDrawColor(rowFont.CellBorders.Colour.R, rowFont.CellBorders.Colour.G, rowFont.CellBorders.Colour.B)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAggregateTableRows(t *testing.T) {
+
+	rows := []map[string]interface{}{
+		{"Region": "North", "Sales": 10.0, "Units": 1.0, "Note": "a"},
+		{"Region": "North", "Sales": 30.0, "Units": "n/a", "Note": nil},
+		{"Region": "South", "Sales": -5.0, "Note": "b"},
+	}
+	columns := []TableColumn{
+		{Key: "Region"},
+		{Key: "Sales", Aggregate: "sum"},
+		{Key: "Units", Aggregate: "average"},
+		{Key: "Note", Aggregate: "count"},
+	}
+
+	//Counts include text but not missing values, sums and averages only look at numbers
+	want := map[string]interface{}{"Sales": 35.0, "Units": 1.0, "Note": 2.0}
+	if aggregates := AggregateTableRows(columns, rows); !reflect.DeepEqual(aggregates, want) {
+		t.Errorf("AggregateTableRows = %v, want %v", aggregates, want)
+	}
+
+	columns = []TableColumn{{Key: "Sales", Aggregate: "min"}, {Key: "Units", Aggregate: "max"}, {Key: "Note", Aggregate: "max"}}
+	want = map[string]interface{}{"Sales": -5.0, "Units": 1.0}
+	if aggregates := AggregateTableRows(columns, rows); !reflect.DeepEqual(aggregates, want) {
+		t.Errorf("AggregateTableRows with mins and maxes = %v, want %v", aggregates, want)
+	}
+
+	//With no rows a sum and count are 0, the rest are left out
+	columns = []TableColumn{{Key: "Sales", Aggregate: "sum"}, {Key: "Note", Aggregate: "count"}, {Key: "Units", Aggregate: "average"}}
+	want = map[string]interface{}{"Sales": 0.0, "Note": 0.0}
+	if aggregates := AggregateTableRows(columns, nil); !reflect.DeepEqual(aggregates, want) {
+		t.Errorf("AggregateTableRows with no rows = %v, want %v", aggregates, want)
+	}
+}
+
+func TestTableRowsForItem(t *testing.T) {
+
+	dataPoints := []interface{}{
+		map[string]interface{}{"Region": "North", "Sales": 10.0},
+		map[string]interface{}{"Region": "South", "Sales": 5.0},
+		map[string]interface{}{"Region": "North", "Sales": 30.0},
+	}
+	columns := []TableColumn{{Key: "Region"}, {Key: "Sales", Aggregate: "sum"}}
+	settings := TableSettings{GroupBy: "Region", Subtotals: true, SubtotalLabel: "Subtotal", GrandTotal: true, TotalLabel: "Total"}
+
+	//Groups are in the order they first appear, and the data rows keep counting across the groups
+	tableRows := TableRowsForItem(columns, settings, dataPoints)
+	want := []TableRow{
+		{Kind: groupHeaderTableRow, Label: "North"},
+		{Kind: dataTableRow, Values: dataPoints[0].(map[string]interface{}), DataIndex: 0},
+		{Kind: dataTableRow, Values: dataPoints[2].(map[string]interface{}), DataIndex: 1},
+		{Kind: subtotalTableRow, Label: "Subtotal", Values: map[string]interface{}{"Sales": 40.0}},
+		{Kind: groupHeaderTableRow, Label: "South"},
+		{Kind: dataTableRow, Values: dataPoints[1].(map[string]interface{}), DataIndex: 2},
+		{Kind: subtotalTableRow, Label: "Subtotal", Values: map[string]interface{}{"Sales": 5.0}},
+		{Kind: totalTableRow, Label: "Total", Values: map[string]interface{}{"Sales": 45.0}},
+	}
+	if !reflect.DeepEqual(tableRows, want) {
+		t.Errorf("TableRowsForItem = %+v, want %+v", tableRows, want)
+	}
+
+	if err := ValidateTableTotals(columns, TableSettings{Subtotals: true}); err == nil {
+		t.Errorf("ValidateTableTotals didn't return an error for subtotals without a groupBy")
+	}
+	if err := ValidateTableTotals([]TableColumn{{Key: "Sales", Aggregate: "median"}}, TableSettings{}); err == nil {
+		t.Errorf("ValidateTableTotals didn't return an error for an unsupported aggregate")
+	}
+}